@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+const toastPrefix = "[toast]"
+
+var plugins *plugin
+
+// Main runs toast, as configured by its command-line flags, in the same way as
+// the toast command. A custom build of toast calls it from its own main func,
+// after registering any Generators to run in-process with Register.
+func Main() {
+	// toast schema writes the schema of the messages between toast and its
+	// plugins, for plugins written in other languages
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		schema(os.Args[2:])
+		return
+	}
+
+	input := flag.String("input", ".", "input directory from where to parse Go code, which the package patterns given as arguments are relative to")
+	buildTags := flag.String("tags", "", "comma-separated build tags selecting files by their build constraints, which are otherwise all collected")
+	tests := flag.Bool("tests", true, "collect _test.go files")
+	debug := flag.Bool("debug", false, "write data from parsed AST to stdout, skips plugins")
+	bodies := flag.Bool("bodies", false, "analyze func and method bodies for their calls, references and returned literals")
+	typeCheck := flag.Bool("types", false, "type-check packages to resolve embedded fields and declarations from other packages")
+	encoding := flag.String("encoding", collector.JSONEncoding, "encoding of the data sent to plugins: json or proto, either optionally compressed with a +gzip suffix")
+	configFile := flag.String("config", "", "JSON file declaring plugins, including options such as filters")
+	flag.Var(plugins, "plugin", "executable plugin for toast to invoke, and the output base directory for files to be written")
+	flag.Parse()
+
+	if !collector.ValidEncoding(*encoding) {
+		exitWithMessage("invalid encoding:", errors.New(*encoding))
+	}
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			exitWithMessage("config error:", err)
+		}
+	}
+
+	if !*debug {
+		// ask each plugin for its requirements before collecting any data, so
+		// that incompatible plugins fail fast, and so that the features they
+		// require are collected
+		err := plugins.each(func(i int, p *plugin) error {
+			return p.shake()
+		})
+		if err != nil {
+			printDiagnostics()
+			exitWithPluginErrors(err)
+		}
+		*typeCheck = *typeCheck || plugins.requires(collector.FeatureTypes)
+		*bodies = *bodies || plugins.requires(collector.FeatureBodies)
+	}
+
+	var tags []string
+	if *buildTags != "" {
+		tags = strings.Split(*buildTags, ",")
+	}
+	data, diags := collector.Load(flag.Args(), collector.Options{
+		Dir:    *input,
+		Tags:   tags,
+		Tests:  *tests,
+		Types:  *typeCheck,
+		Bodies: *bodies,
+	})
+	// problems with the input are reported before, and prevent, running any
+	// plugins
+	diags.Sort()
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	if diags.HasErrors() {
+		os.Exit(1)
+	}
+
+	// debug mode enables users to inspect the raw JSON on the command line
+	if *debug {
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			exitWithMessage("(debug) JSON encode error", err)
+		}
+		// write the data to stdout and skip the plugin execution
+		fmt.Println(string(b))
+		return
+	}
+
+	// plugins sharing a filter share an encoder, and so the bulk of the data
+	// is only filtered and encoded once per filter and encoding
+	var (
+		filtered = make(map[string]*collector.Data)
+		encoders = make(map[string]*collector.Encoder)
+	)
+	err := plugins.each(func(i int, p *plugin) error {
+		filter := p.dataFilter()
+		key, err := json.Marshal(filter)
+		if err != nil {
+			return err
+		}
+		input, ok := filtered[string(key)]
+		if !ok {
+			input = data.Filter(filter)
+			filtered[string(key)] = input
+		}
+
+		// generators registered in-process share the data as it is
+		if p.generator != nil {
+			exe := &runner{
+				p:     p,
+				input: input,
+			}
+			return exe.run()
+		}
+
+		enc, ok := encoders[string(key)]
+		if !ok {
+			enc = collector.NewEncoder(input)
+			encoders[string(key)] = enc
+		}
+
+		// only the output base and parameters are set for each plugin
+		b, err := enc.Encode(p.negotiate(*encoding), p.outputDir, p.params)
+		if err != nil {
+			return err
+		}
+		// set the plugin into a runner and execute it, passing in the data
+		exe := &runner{
+			p:    p,
+			data: bytes.NewReader(b),
+		}
+		if err := exe.run(); err != nil {
+			return err
+		}
+		return nil
+	})
+	printDiagnostics()
+	if err != nil {
+		exitWithPluginErrors(err)
+	}
+}
+
+// schema writes the schema of the messages between toast and its plugins to
+// stdout, in the format selected by its -format flag: the JSON Schema of their
+// JSON encoding, or the protobuf schema of the proto encoding of Data.
+func schema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	format := fs.String("format", "json", "format of the schema: json (JSON Schema) or proto (protobuf)")
+	fs.Parse(args)
+
+	switch *format {
+	case "json":
+		b, err := collector.JSONSchema()
+		if err != nil {
+			exitWithMessage("schema error:", err)
+		}
+		os.Stdout.Write(b)
+	case "proto":
+		fmt.Print(collector.ProtoSchema())
+	default:
+		exitWithMessage("schema error:", fmt.Errorf("unknown format: %s", *format))
+	}
+}
+
+func exitWithPluginErrors(err error) {
+	// err is a collection of errors, one per line, from all of the plugins
+	fmt.Println(toastPrefix, "accumulated plugin errors:")
+	fmt.Println(err)
+	os.Exit(1)
+}
+
+func exitWithMessage(msg string, err error) {
+	fmt.Println(toastPrefix, msg, err)
+	os.Exit(1)
+}
@@ -0,0 +1,481 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+type plugin struct {
+	// cmd is nil for plugins which are registered Generators, run in-process
+	cmd       *exec.Cmd
+	generator *generator
+	outputDir string
+	encoding  string
+	params    map[string]string
+	// filter is set from the config file, and takes precedence over the
+	// filter declared in the plugin's handshake
+	filter *collector.Filter
+	// shakes is set for executables which have been declared to support the
+	// handshake, since those which predate it can't be run safely to ask
+	shakes bool
+	// handshake is nil if the plugin doesn't support the handshake
+	handshake *collector.Handshake
+	// diagnostics are those reported by the plugin in its response
+	diagnostics collector.Diagnostics
+}
+
+type runner struct {
+	p    *plugin
+	data io.Reader
+	// input is the data passed to a plugin run in-process
+	input *collector.Data
+}
+
+const (
+	outPrefix       = "out="
+	encodingOption  = "encoding"
+	paramsOption    = "params"
+	handshakeOption = "handshake"
+	pluginErrPrefix = "[toast:plugin]"
+)
+
+var pluginList []plugin
+
+func (p *plugin) String() string {
+	var all []string
+	for _, plug := range pluginList {
+		all = append(all, fmt.Sprintf(
+			"plugin command: %s, output: [%s]",
+			plug.args(), plug.outputDir,
+		))
+	}
+
+	return strings.Join(all, "\n")
+}
+
+func (p *plugin) Set(value string) error {
+	pluginParts := strings.Split(value, ":")
+	if len(pluginParts) < 2 {
+		return fmt.Errorf("invalid plugin flag value: %s", value)
+	}
+
+	var pluginCmd, pluginOutput string
+	var pluginOptions []string
+
+	pluginCmdVals := strings.Split(pluginParts[0], " ")
+	if len(pluginCmdVals) == 0 {
+		return fmt.Errorf("invalid plugin flag value (bad command): %s", value)
+	}
+
+	pluginCmd = pluginCmdVals[0]
+	if strings.HasPrefix(pluginParts[1], outPrefix) {
+		// plugin was passed no options, and output path is second part
+		pluginOutput = pluginParts[1]
+	} else {
+		return fmt.Errorf("invalid plugin flag value (bad out): %s", value)
+	}
+	if len(pluginCmdVals) > 1 {
+		// plugin was passed options as second part, output is third
+		pluginOptions = pluginCmdVals[1:]
+	}
+
+	outputVals := strings.Split(pluginOutput, "=")
+	if !strings.HasPrefix(pluginOutput, outPrefix) || len(outputVals) < 2 {
+		return fmt.Errorf("invalid plugin out value: %s", pluginOutput)
+	}
+	baseOutputDir := outputVals[1]
+
+	plug, err := newPlugin(pluginCmd, pluginOptions)
+	if err != nil {
+		return fmt.Errorf("invalid plugin flag value (%v): %s", err, value)
+	}
+	plug.outputDir = baseOutputDir
+
+	// any further parts are options, e.g. encoding=proto, params=k=v,k2=v2 or
+	// handshake=true
+	for _, opt := range pluginParts[2:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) < 2 {
+			return fmt.Errorf("invalid plugin option (expected key=value): %s", opt)
+		}
+		switch kv[0] {
+		case encodingOption:
+			if !collector.ValidEncoding(kv[1]) {
+				return fmt.Errorf("invalid plugin option (unknown encoding): %s", opt)
+			}
+			plug.encoding = kv[1]
+		case paramsOption:
+			params, err := parseParams(kv[1])
+			if err != nil {
+				return fmt.Errorf("invalid plugin option (%v): %s", err, opt)
+			}
+			plug.params = params
+		case handshakeOption:
+			shakes, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return fmt.Errorf("invalid plugin option (expected true or false): %s", opt)
+			}
+			plug.shakes = shakes
+		default:
+			return fmt.Errorf("invalid plugin option (unknown option): %s", opt)
+		}
+	}
+
+	pluginList = append(pluginList, plug)
+
+	return nil
+}
+
+// newPlugin returns a plugin running the registered Generator with the name,
+// or otherwise the executable, with args.
+func newPlugin(name string, args []string) (plugin, error) {
+	gen, ok := generators[name]
+	if !ok {
+		return plugin{cmd: exec.Command(name, args...)}, nil
+	}
+	if len(args) > 0 {
+		return plugin{}, fmt.Errorf("in-process plugin %s takes no arguments", name)
+	}
+
+	return plugin{generator: &gen}, nil
+}
+
+// name returns the name by which the plugin was selected.
+func (p *plugin) name() string {
+	if p.generator != nil {
+		return p.generator.plugin.Name()
+	}
+
+	return p.cmd.Args[0]
+}
+
+// path returns the path of the plugin's executable.
+func (p *plugin) path() string {
+	if p.generator != nil {
+		return "in-process"
+	}
+
+	return p.cmd.Path
+}
+
+func (p *plugin) args() []string {
+	if p.generator != nil {
+		return []string{p.name()}
+	}
+
+	return p.cmd.Args
+}
+
+func (p *plugin) each(fn func(idx int, plug *plugin) error) error {
+	errChan := make(chan error)
+	done := make(chan struct{})
+	var errs []string
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+
+			case err := <-errChan:
+				errs = append(errs, err.Error())
+			}
+		}
+	}()
+
+	for i := range pluginList {
+		plug := &pluginList[i]
+		err := fn(i, plug)
+		if err != nil {
+			errChan <- fmt.Errorf(
+				"%s %s: %v (%s)",
+				pluginErrPrefix, plug.name(), err, plug.path(),
+			)
+		}
+	}
+	done <- struct{}{}
+	if errs != nil {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// shake requests the plugin's handshake, and checks that toast is able to meet
+// its requirements. Executables are only asked for a handshake if they have
+// been declared to support it, and those which aren't, or which fail to
+// respond with one, are assumed to have no requirements, and to only support
+// JSON.
+func (p *plugin) shake() error {
+	if p.generator != nil {
+		hs := p.generator.plugin.Handshake()
+		if err := hs.Check(); err != nil {
+			return err
+		}
+		p.handshake = &hs
+		return nil
+	}
+
+	_, err := exec.LookPath(p.cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	if !p.shakes {
+		return nil
+	}
+
+	// a command can only be run once, so a copy is used for the handshake
+	cmd := exec.Command(p.cmd.Path, p.cmd.Args[1:]...)
+	cmd.Env = append(os.Environ(), collector.HandshakeEnv+"=1")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		p.warn("no handshake, sending JSON: %v", err)
+		return nil
+	}
+
+	hs := &collector.Handshake{}
+	if err := json.Unmarshal(out, hs); err != nil {
+		p.warn("no handshake, sending JSON: invalid handshake: %v", err)
+		return nil
+	}
+	if err := hs.Check(); err != nil {
+		return err
+	}
+	p.handshake = hs
+
+	return nil
+}
+
+// warn records a warning about the plugin, which is printed along with the
+// diagnostics it reports.
+func (p *plugin) warn(format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, collector.Diagnostic{
+		Severity: collector.SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+		Source:   p.name(),
+	})
+}
+
+// negotiate returns the encoding to use for the plugin's data, given the
+// encoding preferred by toast.
+func (p *plugin) negotiate(preferred string) string {
+	if p.encoding != "" {
+		preferred = p.encoding
+	}
+	if p.handshake == nil {
+		if p.encoding != "" {
+			return p.encoding
+		}
+		return collector.JSONEncoding
+	}
+
+	return p.handshake.Encoding(preferred)
+}
+
+// dataFilter returns the filter selecting the data sent to the plugin.
+func (p *plugin) dataFilter() collector.Filter {
+	switch {
+	case p.filter != nil:
+		return *p.filter
+	case p.handshake != nil && p.handshake.Filter != nil:
+		return *p.handshake.Filter
+	}
+
+	return collector.Filter{}
+}
+
+// parseParams parses plugin parameters in the form used by protoc, i.e. a
+// comma-separated list of key=value pairs. A key without a value is set to
+// "true", e.g. "table=items,verbose".
+func parseParams(raw string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, param := range strings.Split(raw, ",") {
+		if param == "" {
+			continue
+		}
+		kv := strings.SplitN(param, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("parameter without a name: %q", param)
+		}
+		if len(kv) == 1 {
+			kv = append(kv, "true")
+		}
+		params[kv[0]] = kv[1]
+	}
+
+	return params, nil
+}
+
+// config is the format of the file passed with the -config flag, which
+// declares plugins along with options that are awkward to express in -plugin
+// flags, e.g.
+//
+//	{
+//	  "plugins": [{
+//	    "command": "amdm_gen_db",
+//	    "args": ["-v"],
+//	    "out": "./internal/db",
+//	    "encoding": "proto",
+//	    "handshake": true,
+//	    "filter": {"kinds": ["struct"], "annotations": ["decl:export"]},
+//	    "parameters": {"table": "items"}
+//	  }]
+//	}
+type config struct {
+	Plugins []struct {
+		Command    string            `json:"command"`
+		Args       []string          `json:"args,omitempty"`
+		Out        string            `json:"out"`
+		Encoding   string            `json:"encoding,omitempty"`
+		Handshake  bool              `json:"handshake,omitempty"`
+		Filter     *collector.Filter `json:"filter,omitempty"`
+		Parameters map[string]string `json:"parameters,omitempty"`
+	} `json:"plugins"`
+}
+
+// loadConfig adds the plugins declared in the config file at path.
+func loadConfig(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var conf config
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	for i, p := range conf.Plugins {
+		if p.Command == "" || p.Out == "" {
+			return fmt.Errorf("invalid config file %s: plugin %d requires a command and out", path, i)
+		}
+		if p.Encoding != "" && !collector.ValidEncoding(p.Encoding) {
+			return fmt.Errorf("invalid config file %s: plugin %s has unknown encoding %s", path, p.Command, p.Encoding)
+		}
+		plug, err := newPlugin(p.Command, p.Args)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: plugin %s: %v", path, p.Command, err)
+		}
+		plug.outputDir = p.Out
+		plug.encoding = p.Encoding
+		plug.shakes = p.Handshake
+		plug.params = p.Parameters
+		plug.filter = p.Filter
+		pluginList = append(pluginList, plug)
+	}
+
+	return nil
+}
+
+// printDiagnostics writes the diagnostics reported by all of the plugins to
+// stderr, ordered by their position.
+func printDiagnostics() {
+	var diags collector.Diagnostics
+	for _, plug := range pluginList {
+		diags = append(diags, plug.diagnostics...)
+	}
+	diags.Sort()
+
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
+}
+
+// requires reports whether any of the plugins requires the feature.
+func (p *plugin) requires(feature string) bool {
+	for _, plug := range pluginList {
+		if plug.handshake != nil && plug.handshake.Requires(feature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *runner) run() error {
+	if r.p.generator != nil {
+		resp := r.p.generator.run(*r.input, r.p.outputDir, r.p.params)
+		return r.respond(&resp, nil)
+	}
+
+	r.p.cmd.Stdin = r.data
+	r.p.cmd.Stdout = os.Stdout
+	r.p.cmd.Stderr = os.Stderr
+
+	_, err := exec.LookPath(r.p.cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	// the plugin may send its generated files back through an extra pipe,
+	// which is the first of its ExtraFiles, and so always descriptor 3
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+	r.p.cmd.ExtraFiles = []*os.File{pw}
+	r.p.cmd.Env = append(os.Environ(), collector.ResponseFDEnv+"=3")
+
+	// the response is read while the plugin runs, so that it can't block on
+	// a full pipe
+	response := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(pr)
+		response <- b
+	}()
+
+	err = r.p.cmd.Start()
+	// only the plugin's copy of the write end may remain open, so that the
+	// response is read until the plugin exits
+	pw.Close()
+	if err == nil {
+		err = r.p.cmd.Wait()
+	}
+	b := <-response
+
+	resp := &collector.Response{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, resp); err != nil {
+			return fmt.Errorf("invalid response: %v", err)
+		}
+	}
+
+	return r.respond(resp, err)
+}
+
+// respond records the diagnostics of the plugin's response, and unless the
+// plugin failed with runErr or reported errors, writes its files under its
+// output directory.
+func (r *runner) respond(resp *collector.Response, runErr error) error {
+	for _, d := range resp.Diagnostics {
+		if d.Source == "" {
+			d.Source = r.p.name()
+		}
+		r.p.diagnostics = append(r.p.diagnostics, d)
+	}
+	// the files of a failed plugin are never written
+	if runErr != nil {
+		return runErr
+	}
+	if resp.Diagnostics.HasErrors() {
+		return errors.New("reported errors")
+	}
+
+	for _, f := range resp.Files {
+		if err := f.Write(r.p.outputDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
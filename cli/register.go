@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"github.com/Fanatics/toast/collector"
+	toastplugin "github.com/Fanatics/toast/plugin"
+)
+
+// generator is a Generator registered to run in-process, along with the
+// Plugin providing its files, parameters and diagnostics.
+type generator struct {
+	plugin *toastplugin.Plugin
+	gen    toastplugin.Generator
+}
+
+var generators = make(map[string]generator)
+
+// Register registers a Generator to be run in-process by Main, so that a
+// custom build of toast can run it without the cost of encoding the data for,
+// and starting, a separate process. It is selected in the same way as an
+// executable plugin, by the name of p in a -plugin flag or the command of a
+// plugin in the config file, e.g. --plugin "amdm_gen_db:out=./internal/db".
+// Register must be called before Main.
+func Register(p *toastplugin.Plugin, g toastplugin.Generator) {
+	generators[p.Name()] = generator{
+		plugin: p,
+		gen:    g,
+	}
+}
+
+// run runs the Generator against a copy of d with the output base and
+// parameters of the plugin set.
+func (g generator) run(d collector.Data, outputBase string, params map[string]string) collector.Response {
+	d.OutputBase = outputBase
+	d.Parameters = params
+
+	return g.plugin.Run(g.gen, &d)
+}
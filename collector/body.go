@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// funcBody analyzes the body of a func or method declaration, if enabled. The
+// bodies of func literals within it are skipped, since their calls, references
+// and returns belong to the closure rather than the declaration, e.g. the
+// comparison returned by the less func passed to sort.Slice.
+func (c *FileCollector) funcBody(fn *ast.FuncDecl) *FuncBody {
+	if !c.AnalyzeBodies || fn.Body == nil {
+		return nil
+	}
+
+	var (
+		body  = &FuncBody{}
+		calls = make(map[string]bool)
+		refs  = make(map[string]bool)
+	)
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			return false
+
+		case *ast.CallExpr:
+			if name := c.callName(n.Fun); name != "" && !calls[name] {
+				calls[name] = true
+				body.Calls = append(body.Calls, name)
+			}
+
+		case *ast.Ident:
+			if name := c.refName(n); name != "" && !refs[name] {
+				refs[name] = true
+				body.Refs = append(body.Refs, name)
+			}
+
+		case *ast.ReturnStmt:
+			var (
+				values  []string
+				literal bool
+			)
+			for _, res := range n.Results {
+				values = append(values, typeName(res))
+				literal = literal || isLiteral(res)
+			}
+			if literal {
+				body.Returns = append(body.Returns, Return{
+					Values:   values,
+					Position: position(c.Fset, n.Pos()),
+				})
+			}
+		}
+		return true
+	})
+
+	return body
+}
+
+// callName returns the name of the func or method called by a call expression,
+// or an empty string if the call is a conversion or a call to a builtin.
+func (c *FileCollector) callName(fun ast.Expr) string {
+	fun = unparen(fun)
+
+	if c.Types != nil && c.Types.Info != nil {
+		var ident *ast.Ident
+		switch f := fun.(type) {
+		case *ast.Ident:
+			ident = f
+		case *ast.SelectorExpr:
+			ident = f.Sel
+		case *ast.IndexExpr:
+			return c.callName(f.X)
+		case *ast.IndexListExpr:
+			return c.callName(f.X)
+		}
+		if ident != nil {
+			switch obj := c.Types.Info.Uses[ident].(type) {
+			case *types.Func:
+				return obj.FullName()
+			case *types.Builtin, *types.TypeName:
+				return ""
+			}
+		}
+		if tv, ok := c.Types.Info.Types[fun]; ok && tv.IsType() {
+			return ""
+		}
+	}
+
+	switch f := fun.(type) {
+	case *ast.Ident:
+		// calls to builtins, and conversions to predeclared types
+		if f.Obj == nil && types.Universe.Lookup(f.Name) != nil {
+			return ""
+		}
+		return f.Name
+
+	case *ast.SelectorExpr:
+		return typeName(f)
+
+	case *ast.FuncLit, *ast.ArrayType, *ast.MapType, *ast.ChanType,
+		*ast.FuncType, *ast.InterfaceType, *ast.StructType:
+		return ""
+	}
+
+	return typeName(fun)
+}
+
+// refName returns the name of the package-level var or const referred to by
+// ident, or an empty string if it refers to anything else.
+func (c *FileCollector) refName(ident *ast.Ident) string {
+	if c.Types != nil && c.Types.Info != nil {
+		var obj types.Object
+		switch o := c.Types.Info.Uses[ident].(type) {
+		case *types.Var:
+			obj = o
+		case *types.Const:
+			obj = o
+		default:
+			return ""
+		}
+		if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+			return ""
+		}
+		return obj.Pkg().Path() + "." + obj.Name()
+	}
+
+	// without type information, only declarations within the same file can be
+	// resolved
+	if ident.Obj == nil || (ident.Obj.Kind != ast.Var && ident.Obj.Kind != ast.Con) {
+		return ""
+	}
+	if spec, ok := ident.Obj.Decl.(*ast.ValueSpec); ok && c.fileLevel[spec] {
+		return ident.Name
+	}
+
+	return ""
+}
+
+// isLiteral reports whether expr is a literal value, e.g. 42, "str", nil,
+// true or Item{}.
+func isLiteral(expr ast.Expr) bool {
+	switch e := unparen(expr).(type) {
+	case *ast.BasicLit, *ast.CompositeLit, *ast.FuncLit:
+		return true
+
+	case *ast.Ident:
+		switch e.Name {
+		case "nil", "true", "false", "iota":
+			return e.Obj == nil
+		}
+
+	case *ast.UnaryExpr:
+		return isLiteral(e.X)
+	}
+
+	return false
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
@@ -3,25 +3,29 @@ package collector
 import (
 	"fmt"
 	"go/ast"
+	"go/doc/comment"
 	"go/printer"
 	"go/token"
 	"strings"
 )
 
 const (
-	slashes      = `//`
-	magicPrefix  = slashes + `go:`
-	gogenPrefix  = magicPrefix + `generate`
-	buildPrefix  = slashes + ` +build`
-	ellipsis     = "..."
-	interfaceLit = "interface{}"
-	literalLit   = "literal"
-	typeLit      = "type"
-	mapLit       = "map"
-	arrayLit     = "array"
-	sliceLit     = "slice"
-	funcLit      = "func"
-	chanLit      = "chan"
+	slashes          = `//`
+	magicPrefix      = slashes + `go:`
+	gogenPrefix      = magicPrefix + `generate`
+	buildPrefix      = slashes + ` +build`
+	annotationPrefix = "@"
+	ellipsis         = "..."
+	interfaceLit     = "interface{}"
+	literalLit       = "literal"
+	typeLit          = "type"
+	mapLit           = "map"
+	arrayLit         = "array"
+	sliceLit         = "slice"
+	funcLit          = "func"
+	chanLit          = "chan"
+	structLit        = "struct"
+	ifaceLit         = "interface"
 )
 
 type FileCollector struct {
@@ -36,6 +40,32 @@ type FileCollector struct {
 	MagicComments    []MagicComment
 	GenerateComments []GenerateComment
 	BuildTags        []Constraint
+
+	// UnresolvedMethods are the methods whose receiver types aren't declared
+	// in the file, in the order they're declared. Load adds them to the types
+	// declared by other files of the package.
+	UnresolvedMethods []Method
+
+	// Diagnostics reports the parts of the file which couldn't be collected,
+	// or were collected only as source text, rather than failing.
+	Diagnostics Diagnostics
+
+	// Fset is the file set the file was parsed with, used to resolve the
+	// positions of comments. Positions are omitted if it is nil.
+	Fset *token.FileSet
+
+	// AnalyzeBodies enables the analysis of func and method bodies, collecting
+	// their calls, references and returned literals.
+	AnalyzeBodies bool
+
+	// Types is the type information of the file's package, if available. It
+	// is used to resolve embedded fields and other declarations which may
+	// live in other files or packages.
+	Types *TypeInfo
+
+	// fileLevel holds the file-level var and const declarations, used to
+	// resolve references to them when no type information is available
+	fileLevel map[*ast.ValueSpec]bool
 }
 
 func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
@@ -58,9 +88,26 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 	// collect all file-level imports
 	c.collectImports(file)
 
+	if c.AnalyzeBodies {
+		c.fileLevel = make(map[*ast.ValueSpec]bool)
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range gen.Specs {
+					if vs, ok := spec.(*ast.ValueSpec); ok {
+						c.fileLevel[vs] = true
+					}
+				}
+			}
+		}
+	}
+
 	unresolvedTypes := make(map[string]*TypeDefinition)
+	var receivers []string
 	structs := make(map[string]*Struct)
 	types := make(map[string]*TypeDefinition)
+	// structs and types are collected in the order they're declared, rather
+	// than that of their maps, so that the output is deterministic
+	var structNames, typeNames []string
 	interfaces := make([]Interface, 0)
 	funcs := make([]Func, 0)
 	vars := make([]Var, 0)
@@ -79,24 +126,23 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 				Doc:              normalizeComment(n.Doc),
 				MagicComments:    magicComments,
 				GenerateComments: generateComments,
+				Body:             c.funcBody(n),
 			}
 			if n.Recv != nil {
-				var exportedRecv bool
 				// get the receiver's name and check if it is a pointer
-				for i := range n.Recv.List {
-					if recv, ok := n.Recv.List[i].Type.(*ast.Ident); ok {
-						exportedRecv = isExported(recv)
-						method.Receiver = recv.Name
-						break
-					}
+				if len(n.Recv.List) == 0 {
+					continue
 				}
-				for i := range n.Recv.List {
-					if ptr, ok := n.Recv.List[i].Type.(*ast.StarExpr); ok {
-						method.Receiver = ptr.X.(*ast.Ident).Name
-						method.ReceiverIndirect = true
-						break
-					}
+				recvType := n.Recv.List[0].Type
+				recv, indirect := namedType(recvType)
+				if recv == nil {
+					c.report(recvType.Pos(), SeverityWarning, "method %s: unsupported receiver type %s", n.Name.Name, typeName(recvType))
+					continue
 				}
+				exportedRecv := isExported(recv)
+				method.Receiver = recv.Name
+				method.ReceiverIndirect = indirect
+				method.ReceiverTypeParams = receiverTypeParams(recvType)
 
 				// if the receiver type has already been encountered
 				// and stored in our unresolved type map, add this method to it
@@ -106,6 +152,7 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 				} else {
 					// otherwise, create the type def and insert it into
 					// the struct map
+					receivers = append(receivers, method.Receiver)
 					unresolvedTypes[method.Receiver] = &TypeDefinition{
 						Name:       method.Receiver,
 						IsExported: exportedRecv,
@@ -124,6 +171,8 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 				Results:          funcFields(n.Type.Results),
 				MagicComments:    magicComments,
 				GenerateComments: generateComments,
+				Body:             method.Body,
+				TypeParams:       typeParams(n.Type.TypeParams),
 			})
 
 		case *ast.GenDecl:
@@ -178,196 +227,77 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 					}
 
 				case *ast.TypeSpec:
+					// the doc comment belongs to the GenDecl when the type is
+					// declared alone, e.g. `type Item struct {...}`, and to the
+					// TypeSpec when declared in a group, e.g. `type (...)`
+					docGroup := s.Doc
+					if docGroup == nil && len(n.Specs) == 1 {
+						docGroup = n.Doc
+					}
+
 					// find and stash the structs
 					if strct, ok := s.Type.(*ast.StructType); ok {
-						var fields []StructField
-						for _, field := range strct.Fields.List {
-							var (
-								fType         interface{}
-								indirect      bool
-								isArray       bool
-								arrayLen      string
-								isSlice       bool
-								isMap         bool
-								exportedField bool
-							)
-
-							fName := identName(field.Names)
-							for _, nm := range field.Names {
-								if isExported(nm) {
-									exportedField = true
-									break
-								}
-							}
-
-							switch t := field.Type.(type) {
-							case *ast.SelectorExpr:
-								typ := t.Sel.Name
-								slct := t.X.(*ast.Ident).Name
-								fType = ValueType{
-									Kind:  typeLit,
-									Value: slct + "." + typ,
-								}
-							case *ast.StarExpr:
-								indirect = true
-								// check if we have a selector (within package, etc)
-								// expression prepending the identifier
-								if sel, ok := t.X.(*ast.SelectorExpr); ok {
-									typ := sel.Sel.Name
-									slct := sel.X.(*ast.Ident).Name
-									fType = ValueType{
-										Kind:  typeLit,
-										Value: slct + "." + typ,
-									}
-								} else {
-									switch typ := t.X.(type) {
-									case *ast.Ident:
-										fType = ValueType{
-											Kind:  typeLit,
-											Value: typ.Name,
-										}
-
-									case *ast.ArrayType:
-										var kind string
-										if typ.Len == nil {
-											kind = sliceLit
-										} else {
-											kind = arrayLit
-										}
-
-										fType = ValueType{
-											Kind:  kind,
-											Value: typeName(typ.Elt),
-										}
-									}
-								}
-
-							case *ast.Ident:
-								if star, ok := field.Type.(*ast.StarExpr); ok {
-									indirect = true
-									fType = ValueType{
-										Kind:  typeLit,
-										Value: star.X.(*ast.Ident).Name,
-									}
-								} else {
-									fType = field.Type.(*ast.Ident).Name
-								}
-
-							case *ast.ChanType:
-								fType = ValueType{
-									Kind:  chanLit,
-									Value: channelType(t),
-								}
-
-							case *ast.MapType:
-								isMap = true
-								fType = ValueType{
-									Kind:  mapLit,
-									Value: mapType(t),
-								}
+						fields := c.structFields(strct)
 
-							case *ast.ArrayType:
-								if t.Len == nil {
-									isSlice = true
-								} else {
-									isArray = true
-									switch l := t.Len.(type) {
-									case *ast.BasicLit:
-										arrayLen = l.Value
-									case *ast.Ident:
-										arrayLen = l.Name
-									}
-								}
-								switch fieldType := t.Elt.(type) {
-								case *ast.StarExpr:
-									indirect = true
-									fType = ValueType{
-										Kind:  typeLit,
-										Value: typeName(fieldType.X),
-									}
-
-								case *ast.InterfaceType:
-									fType = interfaceLit
-
-								case *ast.Ident:
-									fType = fieldType.Name
-
-								case *ast.MapType:
-									fType = ValueType{
-										Kind:  mapLit,
-										Value: mapType(fieldType),
-									}
-
-								case *ast.ChanType:
-									fType = ValueType{
-										Kind:  chanLit,
-										Value: channelType(fieldType),
-									}
-								}
-							}
-
-							magic, generate := specialComments(field.Doc)
-							fields = append(fields, StructField{
-								Name:             fName,
-								Type:             fType,
-								Tag:              fieldTag(field),
-								Embed:            fName == "",
-								Indirect:         indirect,
-								IsArray:          isArray,
-								ArrayLen:         arrayLen,
-								IsSlice:          isSlice,
-								IsMap:            isMap,
-								IsExported:       exportedField,
-								Doc:              normalizeComment(field.Doc),
-								Comment:          normalizeComment(field.Comment),
-								MagicComments:    magic,
-								GenerateComments: generate,
-							})
-						}
-
-						doc := normalizeComment(n.Doc)
+						doc := normalizeComment(docGroup)
 						comment := normalizeComment(s.Comment)
+						magic, generate := specialComments(docGroup)
 
 						if strct, ok := structs[s.Name.Name]; ok {
+							strct.IsExported = isExported(s.Name)
 							strct.Doc = doc
 							strct.Comment = comment
+							strct.MagicComments = magic
+							strct.GenerateComments = generate
 							strct.Fields = fields
+							strct.TypeParams = typeParams(s.TypeParams)
 						} else {
+							structNames = append(structNames, s.Name.Name)
 							structs[s.Name.Name] = &Struct{
-								Name:    s.Name.Name,
-								Doc:     doc,
-								Comment: comment,
-								Fields:  fields,
+								IsExported:       isExported(s.Name),
+								Name:             s.Name.Name,
+								Doc:              doc,
+								Comment:          comment,
+								MagicComments:    magic,
+								GenerateComments: generate,
+								Fields:           fields,
+								TypeParams:       typeParams(s.TypeParams),
 							}
 						}
 					}
 
 					// find and stash the interfaces
 					if iface, ok := s.Type.(*ast.InterfaceType); ok {
-						magic, generate := specialComments(s.Doc)
+						magic, generate := specialComments(docGroup)
 						interfaces = append(interfaces, Interface{
 							IsExported:       isExported(s.Name),
 							Name:             s.Name.Name,
-							Doc:              normalizeComment(s.Doc),
+							Doc:              normalizeComment(docGroup),
 							Comment:          normalizeComment(s.Comment),
-							MethodSet:        methodSet(iface),
+							MethodSet:        c.methodSet(iface),
 							MagicComments:    magic,
 							GenerateComments: generate,
+							AllMethods:       c.Types.allMethods(s.Name.Name),
+							TypeParams:       typeParams(s.TypeParams),
 						})
 					}
 
-					// find and stash other type definitions
-					if ident, ok := s.Type.(*ast.Ident); ok {
-						magic, generate := specialComments(s.Doc)
+					// find and stash other type definitions, e.g. `type ID string`
+					// or `type Handler func(Event) error`
+					switch s.Type.(type) {
+					case *ast.StructType, *ast.InterfaceType:
+					default:
+						magic, generate := specialComments(docGroup)
 
 						def := &TypeDefinition{
 							IsExported:       isExported(s.Name),
 							Name:             s.Name.Name,
-							Type:             ident.Name,
-							Doc:              normalizeComment(s.Doc),
+							Type:             typeName(s.Type),
+							Doc:              normalizeComment(docGroup),
 							Comment:          normalizeComment(s.Comment),
 							MagicComments:    magic,
 							GenerateComments: generate,
+							TypeParams:       typeParams(s.TypeParams),
 						}
 
 						// if the type def was already encountered from finding
@@ -377,6 +307,7 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 							def.Methods = td.Methods
 							types[s.Name.Name] = def
 						} else {
+							typeNames = append(typeNames, s.Name.Name)
 							types[s.Name.Name] = def
 						}
 					}
@@ -385,26 +316,44 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 		}
 	}
 
-	for k, v := range structs {
+	for _, k := range structNames {
+		v := structs[k]
 		// capture methods from unresolved type def map and provide to the
 		// actual struct encountered
 		if utd, ok := unresolvedTypes[k]; ok {
 			v.Methods = append(v.Methods, utd.Methods...)
 		}
+		linkTypeParams(v.Methods, v.TypeParams)
+
+		v.Promoted = c.Types.promoted(k)
 
 		c.Structs = append(c.Structs, *v)
 	}
 
-	for k, v := range types {
+	for _, k := range typeNames {
+		v := types[k]
 		// capture methods from unresolved type def map and provide to the
 		// actual type definition encountered
 		if utd, ok := unresolvedTypes[k]; ok {
 			v.Methods = append(v.Methods, utd.Methods...)
 		}
+		linkTypeParams(v.Methods, v.TypeParams)
 
 		c.TypeDefs = append(c.TypeDefs, *v)
 	}
 
+	// the methods of types declared in other files of the package are left
+	// for the caller to resolve
+	for _, k := range receivers {
+		if _, ok := structs[k]; ok {
+			continue
+		}
+		if _, ok := types[k]; ok {
+			continue
+		}
+		c.UnresolvedMethods = append(c.UnresolvedMethods, unresolvedTypes[k].Methods...)
+	}
+
 	c.Vars = vars
 	c.Consts = consts
 	c.Funcs = funcs
@@ -413,6 +362,217 @@ func (c *FileCollector) Visit(node ast.Node) ast.Visitor {
 	return c
 }
 
+// structFields collects the fields of a struct type, including those of any
+// anonymous struct types nested within it.
+func (c *FileCollector) structFields(strct *ast.StructType) []StructField {
+	if strct == nil || strct.Fields == nil {
+		return nil
+	}
+
+	var fields []StructField
+	for _, field := range strct.Fields.List {
+		var (
+			fType         interface{}
+			indirect      bool
+			isArray       bool
+			arrayLen      string
+			isSlice       bool
+			isMap         bool
+			isInterface   bool
+			exportedField bool
+		)
+
+		fName := identName(field.Names)
+		for _, nm := range field.Names {
+			if isExported(nm) {
+				exportedField = true
+				break
+			}
+		}
+
+		switch t := field.Type.(type) {
+		case *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+			// a type from another package, or an instantiated generic type,
+			// e.g. base.Data or List[T]
+			fType = ValueType{
+				Kind:  typeLit,
+				Value: typeName(t),
+			}
+
+		case *ast.StarExpr:
+			indirect = true
+			switch typ := t.X.(type) {
+			case *ast.StructType:
+				fType = c.structType(typ)
+
+			case *ast.ArrayType:
+				var kind string
+				if typ.Len == nil {
+					kind = sliceLit
+				} else {
+					kind = arrayLit
+					arrayLen = typeName(typ.Len)
+				}
+
+				fType = ValueType{
+					Kind:  kind,
+					Value: typeName(typ.Elt),
+				}
+
+			default:
+				fType = ValueType{
+					Kind:  typeLit,
+					Value: typeName(typ),
+				}
+			}
+
+		case *ast.Ident:
+			fType = t.Name
+
+		case *ast.ChanType:
+			fType = ValueType{
+				Kind:  chanLit,
+				Value: channelType(t),
+			}
+
+		case *ast.MapType:
+			isMap = true
+			fType = ValueType{
+				Kind:  mapLit,
+				Value: c.mapType(t),
+			}
+
+		case *ast.StructType:
+			fType = c.structType(t)
+
+		case *ast.InterfaceType:
+			isInterface = true
+			fType = c.interfaceType(t)
+
+		case *ast.FuncType:
+			fType = funcType(t)
+
+		case *ast.ArrayType:
+			if t.Len == nil {
+				isSlice = true
+			} else {
+				isArray = true
+				arrayLen = typeName(t.Len)
+			}
+			switch fieldType := t.Elt.(type) {
+			case *ast.StarExpr:
+				indirect = true
+				fType = ValueType{
+					Kind:  typeLit,
+					Value: typeName(fieldType.X),
+				}
+
+			case *ast.InterfaceType:
+				fType = c.interfaceType(fieldType)
+
+			case *ast.StructType:
+				fType = c.structType(fieldType)
+
+			case *ast.FuncType:
+				fType = funcType(fieldType)
+
+			case *ast.Ident:
+				fType = fieldType.Name
+
+			case *ast.MapType:
+				fType = ValueType{
+					Kind:  mapLit,
+					Value: c.mapType(fieldType),
+				}
+
+			case *ast.ChanType:
+				fType = ValueType{
+					Kind:  chanLit,
+					Value: channelType(fieldType),
+				}
+
+			default:
+				fType = ValueType{
+					Kind:  typeLit,
+					Value: typeName(fieldType),
+				}
+			}
+
+		default:
+			c.report(field.Type.Pos(), SeverityWarning, "field %s: unsupported type %s, collected as its source", fName, typeName(field.Type))
+			fType = typeName(field.Type)
+		}
+
+		magic, generate := specialComments(field.Doc)
+		fields = append(fields, StructField{
+			Name:             fName,
+			Type:             fType,
+			Tag:              fieldTag(field),
+			Embed:            fName == "",
+			Indirect:         indirect,
+			IsArray:          isArray,
+			ArrayLen:         arrayLen,
+			IsSlice:          isSlice,
+			IsMap:            isMap,
+			IsInterface:      isInterface,
+			IsExported:       exportedField,
+			Doc:              normalizeComment(field.Doc),
+			Comment:          normalizeComment(field.Comment),
+			MagicComments:    magic,
+			GenerateComments: generate,
+		})
+	}
+
+	return fields
+}
+
+// structType collects an anonymous struct type, e.g. `Meta struct { A int }`.
+func (c *FileCollector) structType(strct *ast.StructType) ValueType {
+	return ValueType{
+		Kind: structLit,
+		Value: Struct{
+			Fields: c.structFields(strct),
+		},
+	}
+}
+
+// interfaceType collects an anonymous interface type, e.g.
+// `Opts interface{ Apply() }`. The empty interface is kept as its literal.
+func (c *FileCollector) interfaceType(iface *ast.InterfaceType) interface{} {
+	if iface.Methods == nil || len(iface.Methods.List) == 0 {
+		return interfaceLit
+	}
+
+	return ValueType{
+		Kind: ifaceLit,
+		Value: Interface{
+			MethodSet: c.methodSet(iface),
+		},
+	}
+}
+
+// funcType collects the signature of a func type, e.g. `Fn func(int) error`.
+func funcType(fn *ast.FuncType) ValueType {
+	return ValueType{
+		Kind: funcLit,
+		Value: Func{
+			Params:  funcFields(fn.Params),
+			Results: funcFields(fn.Results),
+		},
+	}
+}
+
+// report reports a problem with the part of the file at pos, which is
+// collected as well as possible regardless.
+func (c *FileCollector) report(pos token.Pos, severity, format string, args ...interface{}) {
+	c.Diagnostics = append(c.Diagnostics, Diagnostic{
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Position: position(c.Fset, pos),
+		Source:   diagnosticSource,
+	})
+}
+
 func (c *FileCollector) collectImports(file *ast.File) {
 	if file == nil {
 		return
@@ -440,6 +600,8 @@ func (c *FileCollector) collectComments(file *ast.File) {
 		return
 	}
 
+	assoc := associateComments(file)
+
 	for _, group := range file.Comments {
 		for _, com := range group.List {
 			switch {
@@ -466,35 +628,16 @@ func (c *FileCollector) collectComments(file *ast.File) {
 				}
 			}
 		}
-		c.Comments = append(c.Comments, normalizeComment(group))
+		com := normalizeComment(group)
+		com.Position = position(c.Fset, group.Pos())
+		assoc.relate(&com, group)
+		c.Comments = append(c.Comments, com)
 	}
 }
 
-func mapType(m *ast.MapType) Map {
-	var kv Map
-	switch k := m.Key.(type) {
-	case *ast.Ident:
-		kv.KeyType = k.Name
-
-	case *ast.BasicLit:
-		kv.KeyType = k.Value
-
-	case *ast.StarExpr:
-		if selExp, ok := k.X.(*ast.SelectorExpr); ok {
-			pkg := selExp.X.(*ast.Ident).Name
-			sel := selExp.Sel.Name
-			kv.KeyType = fmt.Sprintf("*%s.%s", pkg, sel)
-		} else {
-			kv.KeyType = "*" + k.X.(*ast.Ident).Name
-		}
-
-	case *ast.SelectorExpr:
-		pkg := k.X.(*ast.Ident).Name
-		sel := k.Sel.Name
-		kv.KeyType = fmt.Sprintf("%s.%s", pkg, sel)
-
-	case *ast.InterfaceType:
-		kv.KeyType = interfaceLit
+func (c *FileCollector) mapType(m *ast.MapType) Map {
+	kv := Map{
+		KeyType: typeName(m.Key),
 	}
 
 	switch v := m.Value.(type) {
@@ -510,42 +653,20 @@ func mapType(m *ast.MapType) Map {
 			Value: v.Value,
 		}
 
-	case *ast.StarExpr:
-		if selExp, ok := v.X.(*ast.SelectorExpr); ok {
-			pkg := selExp.X.(*ast.Ident).Name
-			sel := selExp.Sel.Name
-			kv.ValueType = MapValue{
-				Name:  typeLit,
-				Value: fmt.Sprintf("*%s.%s", pkg, sel),
-			}
-		} else {
-			kv.ValueType = MapValue{
-				Name:  typeLit,
-				Value: "*" + v.X.(*ast.Ident).Name,
-			}
-		}
-
-	case *ast.SelectorExpr:
-		pkg := v.X.(*ast.Ident).Name
-		sel := v.Sel.Name
+	case *ast.StarExpr, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr, *ast.StructType:
+		// pointers, types from other packages, instantiated generic types
+		// and anonymous structs, e.g. *Item, base.Data, List[T] or struct{}
 		kv.ValueType = MapValue{
 			Name:  typeLit,
-			Value: fmt.Sprintf("%s.%s", pkg, sel),
+			Value: typeName(v),
 		}
 
 	case *ast.ArrayType:
 		var size string
-		valTypeName := arrayLit
+		valTypeName := sliceLit
 		if v.Len != nil {
-			valTypeName = sliceLit
-			switch l := v.Len.(type) {
-			case *ast.BasicLit:
-				size = l.Value
-
-			case *ast.Ellipsis:
-				size = ellipsis
-			}
-
+			valTypeName = arrayLit
+			size = typeName(v.Len)
 		}
 		arrType := typeName(v.Elt)
 		kv.ValueType = MapValue{
@@ -556,7 +677,7 @@ func mapType(m *ast.MapType) Map {
 	case *ast.MapType:
 		kv.ValueType = MapValue{
 			Name:  mapLit,
-			Value: mapType(v),
+			Value: c.mapType(v),
 		}
 
 	case *ast.FuncType:
@@ -583,6 +704,13 @@ func mapType(m *ast.MapType) Map {
 				SendOnly: v.Dir == ast.SEND,
 			},
 		}
+
+	default:
+		c.report(v.Pos(), SeverityWarning, "unsupported map value type %s, collected as its source", typeName(v))
+		kv.ValueType = MapValue{
+			Name:  typeLit,
+			Value: typeName(v),
+		}
 	}
 
 	return kv
@@ -677,7 +805,7 @@ func nonStandardComment(com *ast.Comment) interface{} {
 	return nil
 }
 
-func methodSet(iface *ast.InterfaceType) []InterfaceField {
+func (c *FileCollector) methodSet(iface *ast.InterfaceType) []InterfaceField {
 	if iface == nil {
 		return nil
 	}
@@ -686,14 +814,15 @@ func methodSet(iface *ast.InterfaceType) []InterfaceField {
 	for _, field := range iface.Methods.List {
 		switch ifaceField := field.Type.(type) {
 		case *ast.SelectorExpr:
-			pkg := ifaceField.X.(*ast.Ident).Name
-			sel := ifaceField.Sel.Name
 			embd := Interface{
-				Name:       fmt.Sprintf("%s.%s", pkg, sel),
+				Name:       typeName(ifaceField),
 				IsExported: isExported(ifaceField.Sel),
 				Embed:      true,
 			}
-			fields = append(fields, embd)
+			fields = append(fields, InterfaceField{
+				Kind:  EmbedField,
+				Value: embd,
+			})
 
 		case *ast.Ident:
 			embd := Interface{
@@ -701,13 +830,37 @@ func methodSet(iface *ast.InterfaceType) []InterfaceField {
 				IsExported: isExported(ifaceField),
 				Embed:      true,
 			}
-			fields = append(fields, embd)
+			fields = append(fields, InterfaceField{
+				Kind:  EmbedField,
+				Value: embd,
+			})
+
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			// an instantiated generic interface, e.g. Getter[T]
+			embd := Interface{
+				Name:  typeName(ifaceField),
+				Embed: true,
+			}
+			if ident, _ := namedType(ifaceField); ident != nil {
+				embd.IsExported = isExported(ident)
+			}
+			fields = append(fields, InterfaceField{
+				Kind:  EmbedField,
+				Value: embd,
+			})
+
+		case *ast.BinaryExpr, *ast.UnaryExpr:
+			// a union of type terms within a constraint, e.g. ~int | ~string
+			fields = append(fields, InterfaceField{
+				Kind:  TypeTermField,
+				Value: typeTerms(ifaceField),
+			})
 
 		case *ast.FuncType:
 			var name string
 			var exported bool
 			if field.Names != nil {
-				name = typeName(field.Names[0]).(string)
+				name = typeName(field.Names[0])
 				exported = isExported(field.Names[0])
 			}
 			fn := Func{
@@ -718,13 +871,134 @@ func methodSet(iface *ast.InterfaceType) []InterfaceField {
 				Params:     funcFields(ifaceField.Params),
 				Results:    funcFields(ifaceField.Results),
 			}
-			fields = append(fields, fn)
+			fields = append(fields, InterfaceField{
+				Kind:  MethodField,
+				Value: fn,
+			})
+
+		default:
+			// any other type is a single type term, e.g. []byte, which
+			// may only appear in a constraint
+			fields = append(fields, InterfaceField{
+				Kind:  TypeTermField,
+				Value: typeTerms(ifaceField),
+			})
 		}
 	}
 
 	return fields
 }
 
+// typeParams collects the type parameters of a generic type or func, e.g.
+// [K comparable, V any].
+func typeParams(list *ast.FieldList) []TypeParam {
+	if list == nil {
+		return nil
+	}
+
+	var params []TypeParam
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			params = append(params, TypeParam{
+				Name:       name.Name,
+				Constraint: typeName(field.Type),
+			})
+		}
+	}
+
+	return params
+}
+
+// receiverTypeParams collects the type parameters named by the receiver type
+// of a method on a generic type, e.g. K and V for *Pair[K, V]. Their
+// constraints are linked from the type's declaration by linkTypeParams.
+func receiverTypeParams(expr ast.Expr) []TypeParam {
+	var indices []ast.Expr
+	for indices == nil {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			indices = []ast.Expr{t.Index}
+		case *ast.IndexListExpr:
+			indices = t.Indices
+		default:
+			return nil
+		}
+	}
+
+	params := make([]TypeParam, len(indices))
+	for i, index := range indices {
+		params[i].Name = typeName(index)
+	}
+
+	return params
+}
+
+// linkTypeParams sets the constraints of the receiver type parameters of each
+// method from the type parameters declared by its receiver's type, which they
+// correspond to by position.
+func linkTypeParams(methods []Method, params []TypeParam) {
+	for _, m := range methods {
+		for i := range m.ReceiverTypeParams {
+			if i < len(params) {
+				m.ReceiverTypeParams[i].Constraint = params[i].Constraint
+			}
+		}
+	}
+}
+
+// namedType returns the name of a named type, and whether it is a pointer,
+// e.g. List and true for the receiver type *List[T]. It returns nil if the
+// type isn't named.
+func namedType(expr ast.Expr) (*ast.Ident, bool) {
+	var indirect bool
+	for {
+		switch t := expr.(type) {
+		case *ast.Ident:
+			return t, indirect
+		case *ast.StarExpr:
+			indirect = true
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		default:
+			return nil, indirect
+		}
+	}
+}
+
+// typeTerms flattens a union of type terms, e.g. ~int | ~string | float64.
+func typeTerms(expr ast.Expr) []TypeTerm {
+	switch t := expr.(type) {
+	case *ast.BinaryExpr:
+		if t.Op == token.OR {
+			return append(typeTerms(t.X), typeTerms(t.Y)...)
+		}
+
+	case *ast.UnaryExpr:
+		if t.Op == token.TILDE {
+			return []TypeTerm{{
+				Tilde: true,
+				Type:  typeName(t.X),
+			}}
+		}
+
+	case *ast.ParenExpr:
+		return typeTerms(t.X)
+	}
+
+	return []TypeTerm{{
+		Type: typeName(expr),
+	}}
+}
+
 func funcFields(list *ast.FieldList) []Value {
 	if list == nil {
 		return nil
@@ -741,14 +1015,14 @@ func funcFields(list *ast.FieldList) []Value {
 		}
 		vals = append(vals, Value{
 			Name: name,
-			Type: typeName(part.Type).(string),
+			Type: typeName(part.Type),
 		})
 	}
 
 	return vals
 }
 
-func typeName(expr ast.Expr) interface{} {
+func typeName(expr ast.Expr) string {
 	str := &strings.Builder{}
 	printer.Fprint(str, token.NewFileSet(), expr)
 	return str.String()
@@ -762,8 +1036,7 @@ func arrayTypeName(arr *ast.ArrayType) string {
 		return fmt.Sprintf(tmpl, "", arrType)
 	}
 
-	size := arr.Len.(*ast.BasicLit).Value
-	return fmt.Sprintf(tmpl, size, arrType)
+	return fmt.Sprintf(tmpl, typeName(arr.Len), arrType)
 }
 
 func normalizeComment(docs *ast.CommentGroup) Comment {
@@ -771,8 +1044,10 @@ func normalizeComment(docs *ast.CommentGroup) Comment {
 		return Comment{Content: ""}
 	}
 
-	var all []string
+	var all, raw []string
 	for _, c := range docs.List {
+		raw = append(raw, strings.Split(c.Text, "\n")...)
+
 		// ignore non-standard comments, which will be available as properties
 		// objects where appropriate
 		switch nonStandardComment(c).(type) {
@@ -783,7 +1058,96 @@ func normalizeComment(docs *ast.CommentGroup) Comment {
 		all = append(all, strings.TrimSpace(c.Text))
 	}
 
-	return Comment{Content: strings.Join(all, "")}
+	text := docs.Text()
+	return Comment{
+		Content:    strings.Join(all, "\n"),
+		Text:       text,
+		Raw:        raw,
+		Paragraphs: paragraphs(text),
+		Blocks:     commentBlocks(text),
+	}
+}
+
+// paragraphs splits comment text at its blank lines.
+func paragraphs(text string) []string {
+	var paras []string
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.Trim(para, "\n")
+		if para != "" {
+			paras = append(paras, para)
+		}
+	}
+
+	return paras
+}
+
+// commentBlocks parses comment text using the go/doc/comment syntax.
+func commentBlocks(text string) []CommentBlock {
+	if text == "" {
+		return nil
+	}
+
+	var p comment.Parser
+	var blocks []CommentBlock
+	for _, block := range p.Parse(text).Content {
+		switch b := block.(type) {
+		case *comment.Heading:
+			blocks = append(blocks, CommentBlock{
+				Kind: HeadingBlock,
+				Text: plainText(b.Text),
+			})
+
+		case *comment.Paragraph:
+			blocks = append(blocks, CommentBlock{
+				Kind: ParagraphBlock,
+				Text: plainText(b.Text),
+			})
+
+		case *comment.Code:
+			blocks = append(blocks, CommentBlock{
+				Kind: CodeBlock,
+				Text: b.Text,
+			})
+
+		case *comment.List:
+			list := CommentBlock{Kind: ListBlock}
+			for _, item := range b.Items {
+				var paras []string
+				for _, content := range item.Content {
+					if para, ok := content.(*comment.Paragraph); ok {
+						paras = append(paras, plainText(para.Text))
+					}
+				}
+				list.Items = append(list.Items, strings.Join(paras, "\n"))
+				if item.Number != "" {
+					list.Ordered = true
+				}
+			}
+			blocks = append(blocks, list)
+		}
+	}
+
+	return blocks
+}
+
+// plainText flattens the inline text of a parsed comment, dropping any
+// formatting and link targets.
+func plainText(text []comment.Text) string {
+	buf := &strings.Builder{}
+	for _, t := range text {
+		switch t := t.(type) {
+		case comment.Plain:
+			buf.WriteString(string(t))
+		case comment.Italic:
+			buf.WriteString(string(t))
+		case *comment.Link:
+			buf.WriteString(plainText(t.Text))
+		case *comment.DocLink:
+			buf.WriteString(plainText(t.Text))
+		}
+	}
+
+	return buf.String()
 }
 
 func identName(names []*ast.Ident) string {
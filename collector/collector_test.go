@@ -0,0 +1,86 @@
+package collector_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// TestUnsupported checks that the shapes of Go which the collector can't
+// model are reported as warnings at their positions, while the rest of the
+// file is collected regardless.
+func TestUnsupported(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+		line int
+	}{
+		{
+			name: "field type",
+			src:  "type S struct {\n\tP (int)\n}",
+			want: "field P: unsupported type (int), collected as its source",
+			line: 4,
+		},
+		{
+			name: "map value type",
+			src:  "type S struct {\n\tM map[string](int)\n}",
+			want: "unsupported map value type (int), collected as its source",
+			line: 4,
+		},
+		{
+			// only rejected once type-checked
+			name: "receiver type",
+			src:  "type S struct{}\n\nfunc (s []int) M() {}",
+			want: "method M: unsupported receiver type []int",
+			line: 5,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "s.go", "package p\n\n"+c.src+"\n", parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fc := &collector.FileCollector{Fset: fset}
+			ast.Walk(fc, file)
+
+			if len(fc.Structs) != 1 || fc.Structs[0].Name != "S" {
+				t.Errorf("structs = %+v, want S", fc.Structs)
+			}
+			if len(fc.Diagnostics) != 1 {
+				t.Fatalf("diagnostics = %v, want 1", fc.Diagnostics)
+			}
+			d := fc.Diagnostics[0]
+			if d.Severity != collector.SeverityWarning || d.Message != c.want {
+				t.Errorf("diagnostic = %s %q, want %s %q", d.Severity, d.Message, collector.SeverityWarning, c.want)
+			}
+			if d.Position == nil || d.Position.Line != c.line {
+				t.Errorf("diagnostic position = %+v, want line %d", d.Position, c.line)
+			}
+		})
+	}
+}
+
+// TestUndeclaredReceiver checks that methods whose receiver types aren't
+// declared in any file of the package are reported, rather than dropped
+// silently.
+func TestUndeclaredReceiver(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\nfunc (m *Missing) M() {}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, diags := collector.Load(nil, collector.Options{Dir: dir})
+	want := "method Missing.M: receiver type not declared in package p"
+	if len(diags) != 1 || diags[0].Message != want || diags[0].Severity != collector.SeverityWarning {
+		t.Errorf("diagnostics = %v, want a warning %q", diags, want)
+	}
+}
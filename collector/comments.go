@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Relations of a file-level Comment to the declarations around it.
+const (
+	DocRelation         = "doc_of"          // doc comment of Decl
+	LineCommentRelation = "line_comment_of" // line comment of Decl
+	InsideFuncRelation  = "inside_func"     // within the body of func Decl
+	FloatingRelation    = "floating"        // not associated with any declaration
+)
+
+// declRange is a named declaration, and the source range it covers.
+type declRange struct {
+	name     string
+	pos, end token.Pos
+}
+
+// commentAssociations relates each comment group in a file to the declarations
+// it documents or sits between.
+type commentAssociations struct {
+	// owners maps doc and line comments to their declaration
+	owners map[*ast.CommentGroup]commentOwner
+	// decls holds the file-level declarations, in source order
+	decls []declRange
+	// funcs holds the func declarations which have a body
+	funcs []declRange
+}
+
+type commentOwner struct {
+	relation string
+	decl     string
+}
+
+func associateComments(file *ast.File) *commentAssociations {
+	a := &commentAssociations{
+		owners: make(map[*ast.CommentGroup]commentOwner),
+	}
+	if file.Name != nil {
+		a.own("package "+file.Name.Name, file.Doc, nil)
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if recv := receiverName(d); recv != "" {
+				name = recv + "." + name
+			}
+			a.own(name, d.Doc, nil)
+			a.decls = append(a.decls, declRange{name, d.Pos(), d.End()})
+			if d.Body != nil {
+				a.funcs = append(a.funcs, declRange{name, d.Body.Pos(), d.Body.End()})
+			}
+
+		case *ast.GenDecl:
+			var names []string
+			for _, spec := range d.Specs {
+				var name string
+				switch s := spec.(type) {
+				case *ast.ImportSpec:
+					name = s.Path.Value
+					a.own(name, s.Doc, s.Comment)
+
+				case *ast.ValueSpec:
+					name = identName(s.Names)
+					a.own(name, s.Doc, s.Comment)
+
+				case *ast.TypeSpec:
+					name = s.Name.Name
+					a.own(name, s.Doc, s.Comment)
+					a.ownFields(name, s.Type)
+				}
+
+				names = append(names, name)
+				a.decls = append(a.decls, declRange{name, spec.Pos(), spec.End()})
+			}
+			// a lone spec is documented by its GenDecl, otherwise the doc
+			// comment applies to the group as a whole
+			a.own(strings.Join(names, ", "), d.Doc, nil)
+		}
+	}
+
+	return a
+}
+
+// ownFields associates the comments of struct fields and interface methods,
+// including those of nested anonymous types, with their qualified names, e.g.
+// Item.Dimensions.
+func (a *commentAssociations) ownFields(parent string, expr ast.Expr) {
+	var fields *ast.FieldList
+	switch t := expr.(type) {
+	case *ast.StructType:
+		fields = t.Fields
+	case *ast.InterfaceType:
+		fields = t.Methods
+	case *ast.StarExpr:
+		a.ownFields(parent, t.X)
+	case *ast.ArrayType:
+		a.ownFields(parent, t.Elt)
+	}
+	if fields == nil {
+		return
+	}
+
+	for _, field := range fields.List {
+		name := identName(field.Names)
+		if name == "" {
+			name = typeName(field.Type)
+		}
+		name = parent + "." + name
+		a.own(name, field.Doc, field.Comment)
+		a.ownFields(name, field.Type)
+	}
+}
+
+func (a *commentAssociations) own(decl string, doc, comment *ast.CommentGroup) {
+	if doc != nil {
+		a.owners[doc] = commentOwner{relation: DocRelation, decl: decl}
+	}
+	if comment != nil {
+		a.owners[comment] = commentOwner{relation: LineCommentRelation, decl: decl}
+	}
+}
+
+// relate sets the relation of the comment group to the declarations around
+// it.
+func (a *commentAssociations) relate(com *Comment, group *ast.CommentGroup) {
+	com.Relation = FloatingRelation
+	if owner, ok := a.owners[group]; ok {
+		com.Relation = owner.relation
+		com.Decl = owner.decl
+	} else {
+		for _, fn := range a.funcs {
+			if fn.pos <= group.Pos() && group.End() <= fn.end {
+				com.Relation = InsideFuncRelation
+				com.Decl = fn.name
+				break
+			}
+		}
+	}
+
+	for _, decl := range a.decls {
+		if decl.end <= group.Pos() {
+			com.Before = decl.name
+		}
+		if decl.pos >= group.End() {
+			com.After = decl.name
+			break
+		}
+	}
+}
+
+// receiverName returns the name of a method's receiver type, without any
+// pointer indirection or type parameters, e.g. List for *List[T].
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+
+	if ident, _ := namedType(fn.Recv.List[0].Type); ident != nil {
+		return ident.Name
+	}
+
+	return ""
+}
+
+// position returns the position of pos, or nil if no FileSet is available to
+// resolve it.
+func position(fset *token.FileSet, pos token.Pos) *Position {
+	if fset == nil || !pos.IsValid() {
+		return nil
+	}
+
+	p := fset.Position(pos)
+	return &Position{
+		Filename: p.Filename,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
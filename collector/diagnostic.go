@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severities of a Diagnostic.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Diagnostic is a problem found by toast or a plugin, optionally located in a
+// source file and related to a declaration. It implements error, so that a
+// plugin can return one from its Func.
+type Diagnostic struct {
+	Severity string    `json:"severity,omitempty" proto:"1"` // e.g. SeverityError
+	Message  string    `json:"message,omitempty" proto:"2"`
+	Position *Position `json:"position,omitempty" proto:"3"`
+	Decl     string    `json:"decl,omitempty" proto:"4"`   // declaration the diagnostic relates to, e.g. Item.Dimensions
+	Source   string    `json:"source,omitempty" proto:"5"` // plugin which reported the diagnostic, or toast
+}
+
+// String formats the diagnostic in the style of a compiler, e.g.
+// item.go:12:2: error: amdm_gen_db: Item.Dimensions: unsupported type. Parts
+// which are unknown are omitted.
+func (d Diagnostic) String() string {
+	var parts []string
+	if pos := d.Position; pos != nil && pos.Filename != "" {
+		loc := pos.Filename
+		if pos.Line > 0 {
+			loc += ":" + strconv.Itoa(pos.Line)
+			if pos.Column > 0 {
+				loc += ":" + strconv.Itoa(pos.Column)
+			}
+		}
+		parts = append(parts, loc)
+	}
+	severity := d.Severity
+	if severity == "" {
+		severity = SeverityError
+	}
+	parts = append(parts, severity)
+	for _, part := range []string{d.Source, d.Decl, d.Message} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ": ")
+}
+
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// IsError reports whether the diagnostic is an error, which is also the case
+// if its severity is unset.
+func (d Diagnostic) IsError() bool {
+	return d.Severity == SeverityError || d.Severity == ""
+}
+
+// Diagnostics is a list of Diagnostic.
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any of the diagnostics is an error.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.IsError() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sort sorts the diagnostics by their position, placing those without one
+// first, and otherwise keeping the order in which they were reported.
+func (ds Diagnostics) Sort() {
+	sort.SliceStable(ds, func(i, j int) bool {
+		pi, pj := ds[i].Position, ds[j].Position
+		switch {
+		case pi == nil || pj == nil:
+			return pi == nil && pj != nil
+		case pi.Filename != pj.Filename:
+			return pi.Filename < pj.Filename
+		case pi.Line != pj.Line:
+			return pi.Line < pj.Line
+		}
+		return pi.Column < pj.Column
+	})
+}
@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/tidwall/sjson"
+)
+
+// Encodings of Data, as sent to plugins. Any of them may be compressed by
+// appending GzipSuffix, e.g. "proto+gzip".
+const (
+	JSONEncoding  = "json"
+	ProtoEncoding = "proto"
+	GzipSuffix    = "+gzip"
+)
+
+// ProtoVersion is the version of the protobuf schema used by ProtoEncoding. It
+// is incremented whenever a change to the data model alters the number or type
+// of an existing field.
+const ProtoVersion = 1
+
+// The protobuf field numbers of Data.OutputBase and Data.Parameters, which are
+// set on data encoded once for every plugin.
+var (
+	outputBaseField = protoFieldNum(reflect.TypeOf(Data{}), "OutputBase")
+	parametersField = protoFieldNum(reflect.TypeOf(Data{}), "Parameters")
+)
+
+var (
+	// protoMagic prefixes protobuf-encoded data so that it can be told apart
+	// from JSON, which can't begin with a NUL byte. It is followed by the
+	// ProtoVersion byte.
+	protoMagic = []byte("\x00TPB")
+	gzipMagic  = []byte{0x1f, 0x8b}
+)
+
+// ValidEncoding reports whether enc is a supported encoding.
+func ValidEncoding(enc string) bool {
+	switch strings.TrimSuffix(enc, GzipSuffix) {
+	case JSONEncoding, ProtoEncoding:
+		return true
+	}
+
+	return false
+}
+
+// Encode serializes the data using the given encoding.
+func (d *Data) Encode(enc string) ([]byte, error) {
+	return NewEncoder(d).Encode(enc, d.OutputBase, d.Parameters)
+}
+
+// Encoder serializes the same Data many times, e.g. once for each plugin,
+// with a different OutputBase and Parameters each time. The bulk of the data
+// is only encoded once per encoding, and the OutputBase and Parameters are then
+// set within it.
+type Encoder struct {
+	data  *Data
+	cache map[string][]byte
+}
+
+// NewEncoder returns an Encoder for the data.
+func NewEncoder(d *Data) *Encoder {
+	return &Encoder{
+		data:  d,
+		cache: make(map[string][]byte),
+	}
+}
+
+// Encode serializes the data using the given encoding, with its OutputBase
+// and Parameters set to outputBase and params.
+func (e *Encoder) Encode(enc, outputBase string, params map[string]string) ([]byte, error) {
+	base := strings.TrimSuffix(enc, GzipSuffix)
+	if base == "" {
+		base = JSONEncoding
+	}
+
+	b, ok := e.cache[base]
+	if !ok {
+		data := *e.data
+		data.OutputBase = ""
+		data.Parameters = nil
+
+		var err error
+		switch base {
+		case JSONEncoding:
+			b, err = json.Marshal(data)
+
+		case ProtoEncoding:
+			b, err = marshalProto(&data)
+			b = append(append(append([]byte{}, protoMagic...), ProtoVersion), b...)
+
+		default:
+			return nil, fmt.Errorf("unsupported encoding: %s", enc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.cache[base] = b
+	}
+
+	// set the output base and parameters rather than re-encoding all of the
+	// data
+	var err error
+	switch base {
+	case JSONEncoding:
+		b, err = sjson.SetBytes(b, "output_base", outputBase)
+		if err == nil && len(params) > 0 {
+			var raw []byte
+			if raw, err = json.Marshal(params); err == nil {
+				b, err = sjson.SetRawBytes(b, "parameters", raw)
+			}
+		}
+
+	case ProtoEncoding:
+		// the last value of a non-repeated field wins when decoding, and
+		// entries of a map are merged
+		b = append([]byte{}, b...)
+		if outputBase != "" {
+			b = appendBytes(b, outputBaseField, []byte(outputBase))
+		}
+		if len(params) > 0 {
+			b, err = appendValue(b, parametersField, reflect.ValueOf(params), false, false)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(enc, GzipSuffix) {
+		return b, nil
+	}
+
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode deserializes data produced by Encode, detecting the encoding used.
+func Decode(b []byte) (*Data, error) {
+	if bytes.HasPrefix(b, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		b, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := &Data{}
+	if !bytes.HasPrefix(b, protoMagic) {
+		if err := json.Unmarshal(b, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	b = b[len(protoMagic):]
+	if len(b) == 0 || b[0] != ProtoVersion {
+		return nil, fmt.Errorf("unsupported protobuf schema version, expected %d", ProtoVersion)
+	}
+	if err := unmarshalProto(b[1:], data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// DecodeReader reads all of r and deserializes it as Decode does.
+func DecodeReader(r io.Reader) (*Data, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(b)
+}
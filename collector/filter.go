@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"go/token"
+	"strings"
+)
+
+// Kinds of declarations, as used by Filter.
+const (
+	KindStruct    = "struct"
+	KindInterface = "interface"
+	KindTypeDef   = "type_def"
+	KindFunc      = "func"
+	KindConst     = "const"
+	KindVar       = "var"
+)
+
+// Filter selects the parts of Data which are sent to a plugin, so that it
+// doesn't have to decode data it will never use. The zero Filter selects
+// everything.
+type Filter struct {
+	// Packages selects packages by name or import path. A path ending in
+	// "/..." selects every package below it, e.g. github.com/org/repo/...
+	Packages []string `json:"packages,omitempty"`
+	// Kinds selects declarations by kind, e.g. KindStruct.
+	Kinds []string `json:"kinds,omitempty"`
+	// Annotations selects declarations whose doc comment includes at least
+	// one of the annotations, e.g. decl:export selects a declaration
+	// documented with "@decl:export --formats=json".
+	Annotations []string `json:"annotations,omitempty"`
+	// ExportedOnly selects only exported declarations, along with only the
+	// exported fields, promoted fields and methods of each.
+	ExportedOnly bool `json:"exported_only,omitempty"`
+	// OmitComments drops the comments collected for each file, leaving only
+	// the comments attached to declarations.
+	OmitComments bool `json:"omit_comments,omitempty"`
+}
+
+// IsZero reports whether the filter selects everything.
+func (f Filter) IsZero() bool {
+	return len(f.Packages) == 0 && len(f.Kinds) == 0 &&
+		len(f.Annotations) == 0 && !f.ExportedOnly && !f.OmitComments
+}
+
+// Filter returns a copy of the data pruned to the parts selected by the
+// filter. Files and packages left without any declarations are dropped, as
+// are the Implements entries of any types or interfaces which were.
+func (d *Data) Filter(f Filter) *Data {
+	if f.IsZero() {
+		return d
+	}
+
+	out := *d
+	out.Packages = nil
+	// the qualified names of the types which remain, e.g.
+	// github.com/Fanatics/toast/test.Item
+	kept := make(map[string]bool)
+	for _, pkg := range d.Packages {
+		if !f.selectsPackage(pkg) {
+			continue
+		}
+
+		p := pkg
+		p.Files = nil
+		for _, file := range pkg.Files {
+			file, ok := f.file(file)
+			if !ok {
+				continue
+			}
+			p.Files = append(p.Files, file)
+			for _, s := range file.Structs {
+				kept[p.Path+"."+s.Name] = true
+			}
+			for _, t := range file.TypeDefs {
+				kept[p.Path+"."+t.Name] = true
+			}
+			for _, i := range file.Interfaces {
+				kept[p.Path+"."+i.Name] = true
+			}
+		}
+		if len(p.Files) > 0 {
+			out.Packages = append(out.Packages, p)
+		}
+	}
+
+	// implementations are only kept if both the type and the interface are,
+	// so that plugins never see a reference to a type they weren't sent
+	out.Implements = nil
+	for _, impl := range d.Implements {
+		if kept[impl.Type] && kept[impl.Interface] {
+			out.Implements = append(out.Implements, impl)
+		}
+	}
+
+	return &out
+}
+
+func (f Filter) selectsPackage(pkg Package) bool {
+	if len(f.Packages) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.Packages {
+		switch {
+		case pattern == pkg.Name, pattern == pkg.Path:
+			return true
+		case strings.HasSuffix(pattern, "/..."):
+			prefix := strings.TrimSuffix(pattern, "/...")
+			if pkg.Path == prefix || strings.HasPrefix(pkg.Path, prefix+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// file prunes the declarations of a file, reporting whether any remain.
+func (f Filter) file(file File) (File, bool) {
+	if f.OmitComments {
+		file.Comments = nil
+	}
+
+	var structs []Struct
+	for _, s := range file.Structs {
+		if f.selects(KindStruct, s.IsExported, s.Doc) {
+			s.Methods = f.methods(s.Methods)
+			s.Fields = f.fields(s.Fields)
+			s.Promoted = f.promoted(s.Promoted)
+			structs = append(structs, s)
+		}
+	}
+	file.Structs = structs
+
+	var ifaces []Interface
+	for _, i := range file.Interfaces {
+		if f.selects(KindInterface, i.IsExported, i.Doc) {
+			i.MethodSet = f.methodSet(i.MethodSet)
+			i.AllMethods = f.allMethods(i.AllMethods)
+			ifaces = append(ifaces, i)
+		}
+	}
+	file.Interfaces = ifaces
+
+	var defs []TypeDefinition
+	for _, t := range file.TypeDefs {
+		if f.selects(KindTypeDef, t.IsExported, t.Doc) {
+			t.Methods = f.methods(t.Methods)
+			defs = append(defs, t)
+		}
+	}
+	file.TypeDefs = defs
+
+	var funcs []Func
+	for _, fn := range file.Funcs {
+		if f.selects(KindFunc, fn.IsExported, fn.Doc) {
+			funcs = append(funcs, fn)
+		}
+	}
+	file.Funcs = funcs
+
+	var consts []Const
+	for _, c := range file.Consts {
+		if f.selects(KindConst, c.IsExported, c.Doc) {
+			consts = append(consts, c)
+		}
+	}
+	file.Consts = consts
+
+	var vars []Var
+	for _, v := range file.Vars {
+		if f.selects(KindVar, v.IsExported, v.Doc) {
+			vars = append(vars, v)
+		}
+	}
+	file.Vars = vars
+
+	empty := len(structs) == 0 && len(ifaces) == 0 && len(defs) == 0 &&
+		len(funcs) == 0 && len(consts) == 0 && len(vars) == 0
+	// files are only dropped if their declarations were filtered
+	if empty && (len(f.Kinds) > 0 || len(f.Annotations) > 0 || f.ExportedOnly) {
+		return file, false
+	}
+
+	return file, true
+}
+
+func (f Filter) methods(methods []Method) []Method {
+	if !f.ExportedOnly {
+		return methods
+	}
+
+	var exported []Method
+	for _, m := range methods {
+		if m.IsExported {
+			exported = append(exported, m)
+		}
+	}
+
+	return exported
+}
+
+func (f Filter) fields(fields []StructField) []StructField {
+	if !f.ExportedOnly {
+		return fields
+	}
+
+	var exported []StructField
+	for _, field := range fields {
+		if field.IsExported || (field.Embed && embedsExported(field)) {
+			exported = append(exported, field)
+		}
+	}
+
+	return exported
+}
+
+func (f Filter) promoted(promoted []Promoted) []Promoted {
+	if !f.ExportedOnly {
+		return promoted
+	}
+
+	var exported []Promoted
+	for _, p := range promoted {
+		if p.IsExported {
+			exported = append(exported, p)
+		}
+	}
+
+	return exported
+}
+
+// methodSet drops the unexported methods of an interface's method set, leaving
+// its embedded interfaces and type terms.
+func (f Filter) methodSet(fields []InterfaceField) []InterfaceField {
+	if !f.ExportedOnly {
+		return fields
+	}
+
+	var exported []InterfaceField
+	for _, field := range fields {
+		if fn, ok := field.Func(); ok && !fn.IsExported {
+			continue
+		}
+		exported = append(exported, field)
+	}
+
+	return exported
+}
+
+func (f Filter) allMethods(methods []InterfaceMethod) []InterfaceMethod {
+	if !f.ExportedOnly {
+		return methods
+	}
+
+	var exported []InterfaceMethod
+	for _, m := range methods {
+		if m.IsExported {
+			exported = append(exported, m)
+		}
+	}
+
+	return exported
+}
+
+// embedsExported reports whether an embedded field's type is exported, which
+// its name is named after, e.g. Record for *base.Record[T].
+func embedsExported(field StructField) bool {
+	var typ string
+	switch t := field.Type.(type) {
+	case string:
+		typ = t
+	case ValueType:
+		typ, _ = t.Value.(string)
+	}
+	if i := strings.Index(typ, "["); i >= 0 {
+		typ = typ[:i]
+	}
+	typ = typ[strings.LastIndex(typ, ".")+1:]
+	typ = strings.TrimPrefix(typ, "*")
+
+	return token.IsExported(typ)
+}
+
+func (f Filter) selects(kind string, exported bool, doc Comment) bool {
+	if f.ExportedOnly && !exported {
+		return false
+	}
+	if len(f.Kinds) > 0 && !contains(f.Kinds, kind) {
+		return false
+	}
+	if len(f.Annotations) == 0 {
+		return true
+	}
+
+	for _, a := range doc.Annotations() {
+		if contains(f.Annotations, a.Name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package collector_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// TestFilterImplements checks that implementations are only kept while both
+// their type and interface remain in the filtered data.
+func TestFilterImplements(t *testing.T) {
+	const (
+		embeds = "github.com/Fanatics/toast/collector/testdata/embeds"
+		base   = embeds + "/base"
+	)
+
+	data, diags := collector.Load(nil, collector.Options{Dir: "testdata/embeds", Types: true})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	cases := []struct {
+		name   string
+		filter collector.Filter
+		want   []collector.Implementation
+	}{
+		{
+			name:   "all",
+			filter: collector.Filter{},
+			want:   data.Implements,
+		},
+		{
+			name:   "package",
+			filter: collector.Filter{Packages: []string{embeds}},
+			want: []collector.Implementation{
+				{Type: embeds + ".Item", Interface: embeds + ".Entity"},
+				{Type: embeds + ".Item", Interface: embeds + ".Labeler"},
+				{Type: embeds + ".Named", Interface: embeds + ".Labeler"},
+			},
+		},
+		{
+			name:   "packages",
+			filter: collector.Filter{Packages: []string{embeds + "/..."}},
+			want:   data.Implements,
+		},
+		{
+			name:   "kinds",
+			filter: collector.Filter{Kinds: []string{collector.KindStruct}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := data.Filter(c.filter).Implements
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("implements = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestFilterExportedOnly checks that only the exported fields and methods of
+// exported declarations are kept.
+func TestFilterExportedOnly(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+type T struct {
+	A int
+	b int
+	Embedded
+	unexported
+	*Ptr
+}
+
+type Embedded struct{}
+
+type unexported struct{}
+
+type Ptr struct{}
+
+func (T) M() {}
+
+func (T) m() {}
+
+type I interface {
+	M()
+	m()
+}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, diags := collector.Load(nil, collector.Options{Dir: dir})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	file := data.Filter(collector.Filter{ExportedOnly: true}).Packages[0].Files[0]
+	var got []string
+	for _, s := range file.Structs {
+		if s.Name != "T" {
+			continue
+		}
+		for _, f := range s.Fields {
+			if f.Embed {
+				got = append(got, fmt.Sprint("embed ", f.Type))
+				continue
+			}
+			got = append(got, "field "+f.Name)
+		}
+		for _, m := range s.Methods {
+			got = append(got, "method "+m.Name)
+		}
+	}
+	for _, i := range file.Interfaces {
+		for _, m := range i.Methods() {
+			got = append(got, "interface method "+m.Name)
+		}
+	}
+
+	want := []string{
+		"field A",
+		"embed Embedded",
+		"embed {type Ptr}",
+		"method M",
+		"interface method M",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
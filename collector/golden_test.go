@@ -0,0 +1,83 @@
+package collector_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fanatics/toast/collector"
+	"github.com/Fanatics/toast/plugintest"
+)
+
+// goldenCases are the fixture packages in testdata whose collected data is
+// compared with testdata/golden/<name>/data.json. Run the tests with -update
+// to accept changes to the data.
+var goldenCases = []struct {
+	name string
+	dir  string
+	opts collector.Options
+}{
+	{name: "types", dir: "testdata/types"},
+	{name: "generics", dir: "testdata/generics", opts: collector.Options{Types: true}},
+	{name: "embeds", dir: "testdata/embeds", opts: collector.Options{Types: true}},
+	{name: "enums", dir: "testdata/enums", opts: collector.Options{Types: true}},
+	{name: "comments", dir: "testdata/comments"},
+	{name: "bodies", dir: "testdata/bodies", opts: collector.Options{Types: true, Bodies: true}},
+	{name: "unusual", dir: "testdata/unusual", opts: collector.Options{Types: true, Bodies: true}},
+	{name: "test", dir: "../test", opts: collector.Options{Types: true}},
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := c.opts
+			opts.Dir = c.dir
+			// warnings about parts of the fixture which can't be collected
+			// are part of the data, as the diagnostics of each file
+			data, diags := collector.Load(nil, opts)
+			for _, d := range diags {
+				if d.IsError() {
+					t.Error(d)
+				}
+			}
+
+			b, err := json.MarshalIndent(data, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			plugintest.Golden(t, filepath.Join("testdata", "golden", c.name), []collector.GeneratedFile{{
+				Name:    "data.json",
+				Content: string(b) + "\n",
+			}})
+		})
+	}
+}
+
+// TestProtoSchema checks that toast.proto is up to date with the data model,
+// i.e. that go generate has been run since the model last changed.
+func TestProtoSchema(t *testing.T) {
+	b, err := ioutil.ReadFile("toast.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := plugintest.Diff(string(b), collector.ProtoSchema()); diff != "" {
+		t.Errorf("toast.proto is out of date (run go generate):\n%s", diff)
+	}
+}
+
+// TestJSONSchema checks that toast.schema.json is up to date with the data
+// model.
+func TestJSONSchema(t *testing.T) {
+	b, err := ioutil.ReadFile("toast.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := collector.JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := plugintest.Diff(string(b), string(schema)); diff != "" {
+		t.Errorf("toast.schema.json is out of date (run go generate):\n%s", diff)
+	}
+}
@@ -0,0 +1,456 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options configure Load.
+type Options struct {
+	// Dir is the directory the patterns are relative to, "." if empty.
+	Dir string
+	// Tags are the build tags used to select files by their build
+	// constraints, along with the current GOOS and GOARCH. If nil, every file
+	// is loaded regardless of its build constraints, which are collected as
+	// the BuildTags of each File.
+	Tags []string
+	// Tests loads _test.go files, including those of external test packages,
+	// whose import paths are suffixed with _test.
+	Tests bool
+	// Types type-checks the packages, see FeatureTypes.
+	Types bool
+	// Bodies analyzes the bodies of funcs and methods, see FeatureBodies.
+	Bodies bool
+	// Cache, if set, holds the files parsed by previous calls to Load, so
+	// that only files which have changed since are parsed again.
+	Cache *Cache
+}
+
+// Cache holds the files parsed by Load, for reuse by later calls to Load.
+// Files which a call to Load doesn't read, such as those which have been
+// deleted, are dropped from it. It must not be used by concurrent calls.
+type Cache struct {
+	fset  *token.FileSet
+	files map[string]cachedFile
+}
+
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+	file    *ast.File
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		fset:  token.NewFileSet(),
+		files: make(map[string]cachedFile),
+	}
+}
+
+// diagnosticSource is the Source of the diagnostics reported by toast itself.
+const diagnosticSource = "toast"
+
+// Load collects the Go packages matching the patterns, as toast does before
+// running its plugins. A pattern is a directory, or a directory followed by
+// "/..." to also match every directory below it, except for testdata, vendor
+// and those beginning with "." or "_", e.g. "./..." or "./internal/db". If no
+// patterns are given, every package in and below Dir is loaded.
+//
+// Problems found while loading, such as syntax errors, are returned as
+// diagnostics, and the packages are collected as completely as possible
+// regardless. Files which can't be parsed are skipped.
+func Load(patterns []string, opts Options) (*Data, Diagnostics) {
+	l := &loader{
+		opts: opts,
+		fset: token.NewFileSet(),
+	}
+	if opts.Cache != nil {
+		l.fset = opts.Cache.fset
+	}
+	if opts.Tags != nil {
+		ctx := build.Default
+		ctx.BuildTags = opts.Tags
+		l.ctx = &ctx
+	}
+	if l.opts.Dir == "" {
+		l.opts.Dir = "."
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	if opts.Cache != nil {
+		l.seen = make(map[string]bool)
+	}
+
+	var pkgs []loadedPackage
+	for _, dir := range l.dirs(patterns) {
+		pkgs = append(pkgs, l.parseDir(dir)...)
+	}
+
+	if opts.Cache != nil {
+		for path := range opts.Cache.files {
+			if !l.seen[path] {
+				delete(opts.Cache.files, path)
+			}
+		}
+	}
+
+	// type-check all of the packages together, so that declarations from one
+	// can be resolved while collecting another
+	var checker *Checker
+	if opts.Types {
+		checker = NewChecker(l.fset)
+		for _, pkg := range pkgs {
+			checker.Add(pkg.path, pkg.files)
+		}
+	}
+
+	data := &Data{
+		ProtocolVersion: ProtocolVersion,
+		ToastVersion:    Version,
+	}
+	for _, pkg := range pkgs {
+		var info *TypeInfo
+		if checker != nil {
+			info = checker.Check(pkg.path)
+			l.typeErrors(info)
+		}
+		data.Packages = append(data.Packages, l.collect(pkg, info))
+	}
+	if checker != nil {
+		data.Implements = checker.Implementations()
+	}
+
+	return data, l.diags
+}
+
+type loader struct {
+	opts  Options
+	fset  *token.FileSet
+	ctx   *build.Context
+	diags Diagnostics
+	// seen holds the paths of the files read, so that the others can be
+	// dropped from the Cache.
+	seen map[string]bool
+}
+
+// loadedPackage is a package parsed from a directory, along with its import
+// path.
+type loadedPackage struct {
+	name  string
+	path  string
+	files []*ast.File
+}
+
+func (l *loader) report(severity string, pos *Position, format string, args ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+		Source:   diagnosticSource,
+	})
+}
+
+// dirs returns the directories matched by the patterns, in sorted order.
+func (l *loader) dirs(patterns []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, pattern := range patterns {
+		recursive := pattern == "..." || strings.HasSuffix(pattern, "/...")
+		root := filepath.Join(l.opts.Dir, filepath.FromSlash(strings.TrimSuffix(pattern, "...")))
+
+		fi, err := os.Stat(root)
+		if err != nil {
+			l.report(SeverityError, nil, "pattern %s: %v", pattern, err)
+			continue
+		}
+		if !fi.IsDir() {
+			l.report(SeverityError, nil, "pattern %s: not a directory", pattern)
+			continue
+		}
+		if !recursive {
+			add(root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				l.report(SeverityError, nil, "%v", err)
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			name := d.Name()
+			if path != root && (name == "testdata" || name == "vendor" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			add(path)
+			return nil
+		})
+		if err != nil {
+			l.report(SeverityError, nil, "pattern %s: %v", pattern, err)
+		}
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// parseDir parses the Go files in dir, grouped by package name.
+func (l *loader) parseDir(dir string) []loadedPackage {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		l.report(SeverityError, nil, "%v", err)
+		return nil
+	}
+
+	byName := make(map[string]*loadedPackage)
+	var names []string
+	importPath := importPath(dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if !l.opts.Tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if l.ctx != nil {
+			if ok, err := l.ctx.MatchFile(dir, name); err != nil || !ok {
+				continue
+			}
+		}
+
+		file := l.parseFile(filepath.Join(dir, name))
+		if file == nil {
+			continue
+		}
+
+		pkg, ok := byName[file.Name.Name]
+		if !ok {
+			pkg = &loadedPackage{
+				name: file.Name.Name,
+				path: importPath,
+			}
+			// external test packages share the directory, but not the
+			// import path, of the package under test
+			if strings.HasSuffix(pkg.name, "_test") {
+				pkg.path += "_test"
+			}
+			byName[pkg.name] = pkg
+			names = append(names, pkg.name)
+		}
+		pkg.files = append(pkg.files, file)
+	}
+	sort.Strings(names)
+
+	var pkgs []loadedPackage
+	for _, name := range names {
+		pkgs = append(pkgs, *byName[name])
+	}
+
+	return pkgs
+}
+
+// parseFile parses the file at path, or returns it from the cache if it hasn't
+// changed since it was last parsed. Nil is returned if the file can't be
+// parsed.
+func (l *loader) parseFile(path string) *ast.File {
+	var fi os.FileInfo
+	if cache := l.opts.Cache; cache != nil {
+		l.seen[path] = true
+		var err error
+		if fi, err = os.Stat(path); err == nil {
+			cached, ok := cache.files[path]
+			if ok && cached.modTime.Equal(fi.ModTime()) && cached.size == fi.Size() {
+				return cached.file
+			}
+		}
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		l.report(SeverityError, nil, "%v", err)
+		return nil
+	}
+
+	file, err := parser.ParseFile(l.fset, path, src, parser.ParseComments)
+	if err != nil {
+		var list scanner.ErrorList
+		if !errors.As(err, &list) {
+			l.report(SeverityError, &Position{Filename: path}, "%v", err)
+			return nil
+		}
+		for _, e := range list {
+			l.report(SeverityError, &Position{
+				Filename: e.Pos.Filename,
+				Offset:   e.Pos.Offset,
+				Line:     e.Pos.Line,
+				Column:   e.Pos.Column,
+			}, "%s", e.Msg)
+		}
+		return nil
+	}
+
+	if l.opts.Cache != nil && fi != nil {
+		l.opts.Cache.files[path] = cachedFile{
+			modTime: fi.ModTime(),
+			size:    fi.Size(),
+			file:    file,
+		}
+	}
+
+	return file
+}
+
+// typeErrors reports the errors found while type-checking as warnings, since
+// they don't prevent the packages from being collected.
+func (l *loader) typeErrors(info *TypeInfo) {
+	for _, err := range info.Errors {
+		var terr types.Error
+		if !errors.As(err, &terr) {
+			l.report(SeverityWarning, nil, "%v", err)
+			continue
+		}
+		l.report(SeverityWarning, position(terr.Fset, terr.Pos), "%s", terr.Msg)
+	}
+}
+
+// collect collects each of the package's files.
+func (l *loader) collect(pkg loadedPackage, info *TypeInfo) Package {
+	p := Package{
+		Name: pkg.name,
+		Path: pkg.path,
+	}
+	var unresolved [][]Method
+	for _, file := range pkg.files {
+		c := &FileCollector{
+			Fset:          l.fset,
+			AnalyzeBodies: l.opts.Bodies,
+			Types:         info,
+		}
+		ast.Walk(c, file)
+		p.Files = append(p.Files, File{
+			Name:             l.fset.Position(file.Pos()).Filename,
+			Package:          pkg.name,
+			Imports:          c.Imports,
+			BuildTags:        c.BuildTags,
+			Comments:         c.Comments,
+			MagicComments:    c.MagicComments,
+			GenerateComments: c.GenerateComments,
+			Consts:           c.Consts,
+			Vars:             c.Vars,
+			Structs:          c.Structs,
+			TypeDefs:         c.TypeDefs,
+			Interfaces:       c.Interfaces,
+			Funcs:            c.Funcs,
+			Diagnostics:      c.Diagnostics,
+		})
+		l.diags = append(l.diags, c.Diagnostics...)
+		unresolved = append(unresolved, c.UnresolvedMethods)
+	}
+
+	for i, methods := range unresolved {
+		for _, m := range methods {
+			if !p.addMethod(m) {
+				l.report(SeverityWarning, &Position{Filename: p.Files[i].Name},
+					"method %s.%s: receiver type not declared in package %s", m.Receiver, m.Name, p.Name)
+			}
+		}
+	}
+
+	return p
+}
+
+// addMethod adds the method to the struct or type definition of the package
+// declaring its receiver type, linking its receiver's type parameters to
+// those of the type, and reports whether the type was found.
+func (p *Package) addMethod(m Method) bool {
+	for i := range p.Files {
+		file := &p.Files[i]
+		for j := range file.Structs {
+			if s := &file.Structs[j]; s.Name == m.Receiver {
+				s.Methods = append(s.Methods, m)
+				linkTypeParams(s.Methods, s.TypeParams)
+				return true
+			}
+		}
+		for j := range file.TypeDefs {
+			if t := &file.TypeDefs[j]; t.Name == m.Receiver {
+				t.Methods = append(t.Methods, m)
+				linkTypeParams(t.Methods, t.TypeParams)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// importPath determines the import path of the package in dir, using the
+// module path declared by the nearest go.mod file. If no go.mod file is found,
+// the slash-separated directory is used instead.
+func importPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+
+	for root := abs; ; root = filepath.Dir(root) {
+		mod, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			modPath := modulePath(mod)
+			if modPath == "" {
+				break
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil || rel == "." {
+				return modPath
+			}
+			return path.Join(modPath, filepath.ToSlash(rel))
+		}
+		if filepath.Dir(root) == root {
+			break
+		}
+	}
+
+	return filepath.ToSlash(dir)
+}
+
+// modulePath returns the module path declared in the contents of a go.mod
+// file.
+func modulePath(mod []byte) string {
+	for _, line := range strings.Split(string(mod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+
+	return ""
+}
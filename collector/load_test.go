@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCacheDropsDeletedFiles checks that files deleted since the last call to
+// Load are dropped from the Cache.
+func TestCacheDropsDeletedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	for _, path := range []string{a, b} {
+		if err := ioutil.WriteFile(path, []byte("package p\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := NewCache()
+	if _, diags := Load(nil, Options{Dir: dir, Cache: cache}); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if len(cache.files) != 2 {
+		t.Fatalf("got %d cached files, want 2", len(cache.files))
+	}
+
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := Load(nil, Options{Dir: dir, Cache: cache}); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if _, ok := cache.files[b]; ok || len(cache.files) != 1 {
+		t.Errorf("deleted file %s wasn't dropped from the cache", b)
+	}
+}
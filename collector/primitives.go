@@ -1,88 +1,170 @@
 package collector
 
 import (
+	"encoding/json"
 	"fmt"
+	"go/doc/comment"
 	"os/exec"
 	"strings"
 )
 
 type Data struct {
-	OutputBase string    `json:"output_base"`
-	Packages   []Package `json:"packages,omitempty"`
+	OutputBase string    `json:"output_base" proto:"1"`
+	Packages   []Package `json:"packages,omitempty" proto:"2"`
+	// Implements lists every concrete type in Packages which satisfies an
+	// interface declared in Packages. It is only collected when type
+	// information is available.
+	Implements []Implementation `json:"implements,omitempty" proto:"3"`
+	// ProtocolVersion and ToastVersion identify the version of toast which
+	// collected the data, see collector.ProtocolVersion.
+	ProtocolVersion int    `json:"protocol_version,omitempty" proto:"4"`
+	ToastVersion    string `json:"toast_version,omitempty" proto:"5"`
+	// Parameters are the options passed to the plugin by the user, e.g. with
+	// --plugin "gen:out=./db:params=table=items,verbose=true".
+	Parameters map[string]string `json:"parameters,omitempty" proto:"6"`
+}
+
+// Implementers returns the implementations of the interface with the given
+// qualified name, e.g. github.com/Fanatics/toast/test.RPCItem.
+func (d *Data) Implementers(iface string) []Implementation {
+	var impls []Implementation
+	for _, impl := range d.Implements {
+		if impl.Interface == iface {
+			impls = append(impls, impl)
+		}
+	}
+
+	return impls
+}
+
+// InterfacesOf returns the implementations of interfaces by the type with the
+// given qualified name, e.g. github.com/Fanatics/toast/test.Item.
+func (d *Data) InterfacesOf(typ string) []Implementation {
+	var impls []Implementation
+	for _, impl := range d.Implements {
+		if impl.Type == typ {
+			impls = append(impls, impl)
+		}
+	}
+
+	return impls
+}
+
+// Implementation records that a concrete type satisfies an interface. Both are
+// qualified by their package's import path.
+type Implementation struct {
+	Type      string `json:"type,omitempty" proto:"1"`
+	Interface string `json:"interface,omitempty" proto:"2"`
+	Indirect  bool   `json:"indirect,omitempty" proto:"3"` // only a pointer to Type satisfies Interface
 }
 
 type Package struct {
-	Name  string `json:"name,omitempty"`
-	Files []File `json:"files,omitempty"`
+	Name  string `json:"name,omitempty" proto:"1"`
+	Path  string `json:"path,omitempty" proto:"2"`
+	Files []File `json:"files,omitempty" proto:"3"`
 }
 
 type File struct {
-	Name             string            `json:"name,omitempty"`
-	Package          string            `json:"package,omitempty"`
-	Imports          []Import          `json:"imports,omitempty"`
-	TypeDefs         []TypeDefinition  `json:"type_defs,omitempty"`
-	Structs          []Struct          `json:"structs,omitempty"`
-	Interfaces       []Interface       `json:"interfaces,omitempty"`
-	Funcs            []Func            `json:"funcs,omitempty"`
-	Consts           []Const           `json:"consts,omitempty"`
-	Vars             []Var             `json:"vars,omitempty"`
-	Comments         []Comment         `json:"comments,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
-	BuildTags        []Constraint      `json:"build_tags,omitempty"`
+	Name             string            `json:"name,omitempty" proto:"1"`
+	Package          string            `json:"package,omitempty" proto:"2"`
+	Imports          []Import          `json:"imports,omitempty" proto:"3"`
+	TypeDefs         []TypeDefinition  `json:"type_defs,omitempty" proto:"4"`
+	Structs          []Struct          `json:"structs,omitempty" proto:"5"`
+	Interfaces       []Interface       `json:"interfaces,omitempty" proto:"6"`
+	Funcs            []Func            `json:"funcs,omitempty" proto:"7"`
+	Consts           []Const           `json:"consts,omitempty" proto:"8"`
+	Vars             []Var             `json:"vars,omitempty" proto:"9"`
+	Comments         []Comment         `json:"comments,omitempty" proto:"10"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"11"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"12"`
+	BuildTags        []Constraint      `json:"build_tags,omitempty" proto:"13"`
+	// Diagnostics reports the parts of the file which toast couldn't collect,
+	// or collected only as source text.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty" proto:"14"`
 }
 
 type StructField struct {
-	Indirect         bool              `json:"indirect,omitempty"`
-	Embed            bool              `json:"embed,omitempty"`
-	IsMap            bool              `json:"is_map,omitempty"`
-	IsExported       bool              `json:"is_exported,omitempty"`
-	IsInterface      bool              `json:"is_interface,omitempty"`
-	IsSlice          bool              `json:"is_slice,omitempty"`
-	IsArray          bool              `json:"is_array,omitempty"`
-	ArrayLen         string            `json:"array_length,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
-	Type             interface{}       `json:"field_type,omitempty"`
-	Tag              string            `json:"tag,omitempty"`
+	Indirect         bool              `json:"indirect,omitempty" proto:"1"`
+	Embed            bool              `json:"embed,omitempty" proto:"2"`
+	IsMap            bool              `json:"is_map,omitempty" proto:"3"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"4"`
+	IsInterface      bool              `json:"is_interface,omitempty" proto:"5"`
+	IsSlice          bool              `json:"is_slice,omitempty" proto:"6"`
+	IsArray          bool              `json:"is_array,omitempty" proto:"7"`
+	ArrayLen         string            `json:"array_length,omitempty" proto:"8"`
+	Name             string            `json:"name,omitempty" proto:"9"`
+	Doc              Comment           `json:"doc,omitempty" proto:"10"`
+	Comment          Comment           `json:"comment,omitempty" proto:"11"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"12"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"13"`
+	Type             interface{}       `json:"field_type,omitempty" proto:"14"`
+	Tag              string            `json:"tag,omitempty" proto:"15"`
 }
 
 type Method struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
-	Receiver         string            `json:"receiver,omitempty"`
-	ReceiverIndirect bool              `json:"receiver_indirect,omitempty"`
-	Params           []Value           `json:"params,omitempty"`
-	Results          []Value           `json:"results,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Name             string            `json:"name,omitempty" proto:"2"`
+	Doc              Comment           `json:"doc,omitempty" proto:"3"`
+	Comment          Comment           `json:"comment,omitempty" proto:"4"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"5"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"6"`
+	Receiver         string            `json:"receiver,omitempty" proto:"7"`
+	ReceiverIndirect bool              `json:"receiver_indirect,omitempty" proto:"8"`
+	Params           []Value           `json:"params,omitempty" proto:"9"`
+	Results          []Value           `json:"results,omitempty" proto:"10"`
+	Body             *FuncBody         `json:"body,omitempty" proto:"11"`
+	// ReceiverTypeParams are the type parameters of a method on a generic
+	// type, as named by its receiver, e.g. K and V for (p *Pair[K, V]). Each
+	// is linked to the type parameter of the receiver's type at the same
+	// position, whose constraint it shares.
+	ReceiverTypeParams []TypeParam `json:"receiver_type_params,omitempty" proto:"12"`
+}
+
+// TypeParam is a type parameter of a generic type or func, e.g. K comparable.
+type TypeParam struct {
+	Name string `json:"name,omitempty" proto:"1"`
+	// Constraint is the constraint of the type parameter, as written in
+	// source, e.g. comparable or ~int | ~string.
+	Constraint string `json:"constraint,omitempty" proto:"2"`
 }
 
 type Struct struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
-	Fields           []StructField     `json:"fields,omitempty"`
-	Methods          []Method          `json:"methods,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Name             string            `json:"name,omitempty" proto:"2"`
+	Doc              Comment           `json:"doc,omitempty" proto:"3"`
+	Comment          Comment           `json:"comment,omitempty" proto:"4"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"5"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"6"`
+	Fields           []StructField     `json:"fields,omitempty" proto:"7"`
+	Methods          []Method          `json:"methods,omitempty" proto:"8"`
+	Promoted         []Promoted        `json:"promoted,omitempty" proto:"9"`
+	TypeParams       []TypeParam       `json:"type_params,omitempty" proto:"10"`
+}
+
+// Promoted is a field or method promoted to a struct from one of its embedded
+// fields. It is only collected when type information is available.
+type Promoted struct {
+	IsExported      bool     `json:"is_exported,omitempty" proto:"1"`
+	IsMethod        bool     `json:"is_method,omitempty" proto:"2"`
+	Name            string   `json:"name,omitempty" proto:"3"`
+	Type            string   `json:"type,omitempty" proto:"4"`             // field type or method signature
+	Path            []string `json:"path,omitempty" proto:"5"`             // embedded fields it is reached through, e.g. [Data AuditLog]
+	Origin          string   `json:"origin,omitempty" proto:"6"`           // declaring type, e.g. github.com/Fanatics/toast/test/base.AuditLog
+	Depth           int      `json:"depth,omitempty" proto:"7"`            // embedding depth, 1 for a directly embedded field
+	Indirect        bool     `json:"indirect,omitempty" proto:"8"`         // reached through an embedded pointer
+	PointerReceiver bool     `json:"pointer_receiver,omitempty" proto:"9"` // method declared on a pointer receiver
 }
 
 type TypeDefinition struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Type             string            `json:"type,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
-	Methods          []Method          `json:"methods,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Name             string            `json:"name,omitempty" proto:"2"`
+	Type             string            `json:"type,omitempty" proto:"3"`
+	Doc              Comment           `json:"doc,omitempty" proto:"4"`
+	Comment          Comment           `json:"comment,omitempty" proto:"5"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"6"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"7"`
+	Methods          []Method          `json:"methods,omitempty" proto:"8"`
+	TypeParams       []TypeParam       `json:"type_params,omitempty" proto:"9"`
 }
 
 type Map struct {
@@ -100,14 +182,33 @@ type MapValue struct {
 }
 
 type Func struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
-	Params           []Value           `json:"params,omitempty"`
-	Results          []Value           `json:"results,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Name             string            `json:"name,omitempty" proto:"2"`
+	Doc              Comment           `json:"doc,omitempty" proto:"3"`
+	Comment          Comment           `json:"comment,omitempty" proto:"4"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"5"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"6"`
+	Params           []Value           `json:"params,omitempty" proto:"7"`
+	Results          []Value           `json:"results,omitempty" proto:"8"`
+	Body             *FuncBody         `json:"body,omitempty" proto:"9"`
+	TypeParams       []TypeParam       `json:"type_params,omitempty" proto:"10"`
+}
+
+// FuncBody is the analysis of the body of a func or method, which is only
+// collected when enabled. When type information is available, the names of
+// funcs and declarations are qualified by their package's import path, e.g.
+// (*github.com/Fanatics/toast/test.Item).Export; otherwise they are recorded
+// as written in source, e.g. i.Export.
+type FuncBody struct {
+	Calls   []string `json:"calls,omitempty" proto:"1"`   // funcs and methods called
+	Refs    []string `json:"refs,omitempty" proto:"2"`    // package-level vars and consts referenced
+	Returns []Return `json:"returns,omitempty" proto:"3"` // return statements with literal values
+}
+
+// Return is a return statement which returns at least one literal value.
+type Return struct {
+	Values   []string  `json:"values,omitempty" proto:"1"` // each returned expression, as written in source
+	Position *Position `json:"position,omitempty" proto:"2"`
 }
 
 type Channel struct {
@@ -122,8 +223,8 @@ type ValueType struct {
 }
 
 type Value struct {
-	Name *string `json:"name,omitempty"`
-	Type string  `json:"type,omitempty"`
+	Name *string `json:"name,omitempty" proto:"1"`
+	Type string  `json:"type,omitempty" proto:"2"`
 }
 
 func (v Value) String() string {
@@ -135,35 +236,173 @@ func (v Value) String() string {
 }
 
 type Interface struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Embed            bool              `json:"embed,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MethodSet        []InterfaceField  `json:"method_set,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Embed            bool              `json:"embed,omitempty" proto:"2"`
+	Name             string            `json:"name,omitempty" proto:"3"`
+	Doc              Comment           `json:"doc,omitempty" proto:"4"`
+	Comment          Comment           `json:"comment,omitempty" proto:"5"`
+	MethodSet        []InterfaceField  `json:"method_set,omitempty" proto:"6"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"7"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"8"`
+	// AllMethods is the complete method set of the interface, with embedded
+	// interfaces expanded, and the doc comments of each method copied from
+	// its declaration. It is only collected when type information is
+	// available, i.e. with --types or Options.Types.
+	AllMethods []InterfaceMethod `json:"all_methods,omitempty" proto:"9"`
+	TypeParams []TypeParam       `json:"type_params,omitempty" proto:"10"`
+}
+
+// InterfaceMethod is a method within the complete method set of an interface.
+type InterfaceMethod struct {
+	Func   `proto:"1"`
+	Origin string `json:"origin,omitempty" proto:"2"` // interface declaring the method, e.g. github.com/Fanatics/toast/test/base.EmbedMe
+}
+
+// Methods returns the methods declared directly within the interface's method
+// set.
+func (i Interface) Methods() []Func {
+	var fns []Func
+	for _, field := range i.MethodSet {
+		if fn, ok := field.Func(); ok {
+			fns = append(fns, fn)
+		}
+	}
+
+	return fns
+}
+
+// Embeds returns the interfaces embedded within the interface's method set.
+func (i Interface) Embeds() []Interface {
+	var embeds []Interface
+	for _, field := range i.MethodSet {
+		if embd, ok := field.Interface(); ok {
+			embeds = append(embeds, embd)
+		}
+	}
+
+	return embeds
+}
+
+// TypeUnions returns the unions of type terms within the interface's method
+// set, which are only permitted in interfaces used as type constraints.
+func (i Interface) TypeUnions() [][]TypeTerm {
+	var unions [][]TypeTerm
+	for _, field := range i.MethodSet {
+		if terms, ok := field.TypeTerms(); ok {
+			unions = append(unions, terms)
+		}
+	}
+
+	return unions
+}
+
+// Kinds of InterfaceField.
+const (
+	MethodField   = "method"
+	EmbedField    = "embed"
+	TypeTermField = "type_term"
+)
+
+// InterfaceField is an element of an interface's method set. Its Kind
+// determines the type of its Value:
+//
+//	MethodField   Func
+//	EmbedField    Interface (with Embed set, and only its Name known)
+//	TypeTermField []TypeTerm
+type InterfaceField struct {
+	Kind  string      `json:"kind"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Func returns the method held by the field, if it is a MethodField.
+func (f InterfaceField) Func() (Func, bool) {
+	fn, ok := f.Value.(Func)
+	return fn, ok && f.Kind == MethodField
+}
+
+// Interface returns the embedded interface held by the field, if it is an
+// EmbedField.
+func (f InterfaceField) Interface() (Interface, bool) {
+	embd, ok := f.Value.(Interface)
+	return embd, ok && f.Kind == EmbedField
+}
+
+// TypeTerms returns the union of type terms held by the field, if it is a
+// TypeTermField.
+func (f InterfaceField) TypeTerms() ([]TypeTerm, bool) {
+	terms, ok := f.Value.([]TypeTerm)
+	return terms, ok && f.Kind == TypeTermField
 }
 
-type InterfaceField interface{}
+// UnmarshalJSON decodes the field's Value into the concrete type indicated by
+// its Kind, rather than into a generic map.
+func (f *InterfaceField) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Kind  string          `json:"kind"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	f.Kind = raw.Kind
+	f.Value = nil
+	if len(raw.Value) == 0 {
+		return nil
+	}
+
+	var err error
+	switch raw.Kind {
+	case MethodField:
+		var fn Func
+		err = json.Unmarshal(raw.Value, &fn)
+		f.Value = fn
+
+	case EmbedField:
+		var embd Interface
+		err = json.Unmarshal(raw.Value, &embd)
+		f.Value = embd
+
+	case TypeTermField:
+		var terms []TypeTerm
+		err = json.Unmarshal(raw.Value, &terms)
+		f.Value = terms
+
+	default:
+		var v interface{}
+		err = json.Unmarshal(raw.Value, &v)
+		f.Value = v
+	}
+	if err != nil {
+		return fmt.Errorf("decode %s interface field: %v", raw.Kind, err)
+	}
+
+	return nil
+}
+
+// TypeTerm is a single term of a type union, e.g. ~int in ~int | ~string.
+type TypeTerm struct {
+	Tilde bool   `json:"tilde,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
 
 type Import struct {
-	Name             string            `json:"name,omitempty"`
-	Path             string            `json:"path,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
+	Name             string            `json:"name,omitempty" proto:"1"`
+	Path             string            `json:"path,omitempty" proto:"2"`
+	Doc              Comment           `json:"doc,omitempty" proto:"3"`
+	Comment          Comment           `json:"comment,omitempty" proto:"4"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"5"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"6"`
 }
 
 type MagicComment struct {
-	Pragma string `json:"pragma,omitempty"` // noinline
-	Raw    string `json:"raw,omitempty"`    // go:noinline
+	Pragma string `json:"pragma,omitempty" proto:"1"` // noinline
+	Raw    string `json:"raw,omitempty" proto:"2"`    // go:noinline
 }
 
 type GenerateComment struct {
-	Command string `json:"command,omitempty"` // goyacc -o gopher.go -p parser gopher.y
-	Raw     string `json:"raw,omitempty"`     // go:generate goyacc -o gopher.go -p parser gopher.y
+	Command string `json:"command,omitempty" proto:"1"` // goyacc -o gopher.go -p parser gopher.y
+	Raw     string `json:"raw,omitempty" proto:"2"`     // go:generate goyacc -o gopher.go -p parser gopher.y
 }
 
 func (g GenerateComment) Cmd() (*exec.Cmd, error) {
@@ -176,62 +415,148 @@ func (g GenerateComment) Cmd() (*exec.Cmd, error) {
 }
 
 type Comment struct {
-	Content string `json:"content,omitempty"`
+	// Content is the comment as written in source, excluding any magic or
+	// generate comments, with its lines separated by "\n".
+	Content string `json:"content,omitempty" proto:"1"`
+	// Text is the text of the comment with comment markers, directives and
+	// surrounding blank lines removed, the same as ast.CommentGroup.Text.
+	Text string `json:"text,omitempty" proto:"2"`
+	// Raw holds every line of the comment as written in source, including
+	// comment markers and directives.
+	Raw []string `json:"raw,omitempty" proto:"3"`
+	// Paragraphs holds the text of the comment split at blank lines.
+	Paragraphs []string `json:"paragraphs,omitempty" proto:"4"`
+	// Blocks is the structure of the comment as parsed by go/doc/comment, i.e.
+	// its headings, paragraphs, lists and code blocks.
+	Blocks []CommentBlock `json:"blocks,omitempty" proto:"5"`
+
+	// The following are only set for the comments of a File, and relate each
+	// comment to the declarations around it.
+
+	Position *Position `json:"position,omitempty" proto:"6"`
+	Relation string    `json:"relation,omitempty" proto:"7"` // e.g. doc_of, see DocRelation
+	Decl     string    `json:"decl,omitempty" proto:"8"`     // declaration the comment is related to, e.g. Item.Dimensions
+	Before   string    `json:"before,omitempty" proto:"9"`   // nearest preceding file-level declaration
+	After    string    `json:"after,omitempty" proto:"10"`   // nearest following file-level declaration
+}
+
+// Position is a location within a source file.
+type Position struct {
+	Filename string `json:"filename,omitempty" proto:"1"`
+	Offset   int    `json:"offset,omitempty" proto:"2"`
+	Line     int    `json:"line,omitempty" proto:"3"`
+	Column   int    `json:"column,omitempty" proto:"4"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 // Lines converts any comment into a slice of strings based on their logical
 // line-based grouping.
 func (c Comment) Lines() []string {
-	const pref = "/*"
-	const suff = "*/"
-	const basic = "// "
-
-	com := c.Content
-	switch {
-	// check if multi-line /* ... */ style comment
-	case strings.HasPrefix(com, pref) && strings.HasSuffix(com, suff):
-		com = strings.Trim(com, pref+suff)
-		com = strings.Replace(com, "\n\n", "\n", -1)
-		com = strings.TrimPrefix(com, "\n")
-		com = strings.TrimSuffix(com, "\n")
-		return strings.Split(com, "\n")
-
-	// check if basic comment, prefixed with //(+space)
-	case strings.HasPrefix(com, basic):
-		return strings.Split(com, basic)
+	if c.Text == "" {
+		return nil
 	}
 
-	return nil
+	return strings.Split(strings.TrimSuffix(c.Text, "\n"), "\n")
+}
+
+// Annotations returns the annotations within the comment, which are lines
+// beginning with "@", e.g. "@decl:export --formats=json,csv".
+func (c Comment) Annotations() []Annotation {
+	var annotations []Annotation
+	for _, line := range c.Lines() {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, annotationPrefix) || len(line) == 1 {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, annotationPrefix), " ", 2)
+		a := Annotation{
+			Name: parts[0],
+			Raw:  line,
+		}
+		if len(parts) > 1 {
+			a.Args = strings.TrimSpace(parts[1])
+		}
+		annotations = append(annotations, a)
+	}
+
+	return annotations
+}
+
+// Annotation is a line within a comment beginning with "@".
+type Annotation struct {
+	Name string `json:"name,omitempty"` // decl:export
+	Args string `json:"args,omitempty"` // --formats=json,csv --providers=s3
+	Raw  string `json:"raw,omitempty"`  // @decl:export --formats=json,csv --providers=s3
+}
+
+// Markdown renders the comment as Markdown, following the go/doc/comment
+// syntax for headings, lists, code blocks and links.
+func (c Comment) Markdown() string {
+	var p comment.Printer
+	return string(p.Markdown(c.doc()))
+}
+
+// HTML renders the comment as HTML, following the go/doc/comment syntax for
+// headings, lists, code blocks and links.
+func (c Comment) HTML() string {
+	var p comment.Printer
+	return string(p.HTML(c.doc()))
+}
+
+func (c Comment) doc() *comment.Doc {
+	var p comment.Parser
+	return p.Parse(c.Text)
+}
+
+// Kinds of CommentBlock.
+const (
+	HeadingBlock   = "heading"
+	ParagraphBlock = "paragraph"
+	ListBlock      = "list"
+	CodeBlock      = "code"
+)
+
+// CommentBlock is a block of a doc comment, e.g. a heading or code block.
+type CommentBlock struct {
+	Kind    string   `json:"kind" proto:"1"`
+	Text    string   `json:"text,omitempty" proto:"2"`    // text of a heading, paragraph or code block
+	Items   []string `json:"items,omitempty" proto:"3"`   // text of each list item
+	Ordered bool     `json:"ordered,omitempty" proto:"4"` // list items are numbered
 }
 
 type Const struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Type             string            `json:"type,omitempty"`
-	Value            interface{}       `json:"value,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Name             string            `json:"name,omitempty" proto:"2"`
+	Type             string            `json:"type,omitempty" proto:"3"`
+	Value            interface{}       `json:"value,omitempty" proto:"4"`
+	Doc              Comment           `json:"doc,omitempty" proto:"5"`
+	Comment          Comment           `json:"comment,omitempty" proto:"6"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"7"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"8"`
 }
 
 type Var struct {
-	IsExported       bool              `json:"is_exported,omitempty"`
-	Name             string            `json:"name,omitempty"`
-	Type             string            `json:"type,omitempty"`
-	Value            interface{}       `json:"value,omitempty"`
-	Doc              Comment           `json:"doc,omitempty"`
-	Comment          Comment           `json:"comment,omitempty"`
-	MagicComments    []MagicComment    `json:"magic_comments,omitempty"`
-	GenerateComments []GenerateComment `json:"generate_comments,omitempty"`
+	IsExported       bool              `json:"is_exported,omitempty" proto:"1"`
+	Name             string            `json:"name,omitempty" proto:"2"`
+	Type             string            `json:"type,omitempty" proto:"3"`
+	Value            interface{}       `json:"value,omitempty" proto:"4"`
+	Doc              Comment           `json:"doc,omitempty" proto:"5"`
+	Comment          Comment           `json:"comment,omitempty" proto:"6"`
+	MagicComments    []MagicComment    `json:"magic_comments,omitempty" proto:"7"`
+	GenerateComments []GenerateComment `json:"generate_comments,omitempty" proto:"8"`
 }
 
 // Constraint holds the options of a build tag.
-// +build linux,386 darwin,!cgo
-//        |-------| |---------|
-//         option      option
+//
+//	// +build linux,386 darwin,!cgo
+//	          |-------| |---------|
+//	           option      option
 type Constraint struct {
-	Options []string `json:"options,omitempty"`
+	Options []string `json:"options,omitempty" proto:"1"`
 }
 
 func (c Constraint) String() string {
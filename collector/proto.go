@@ -0,0 +1,424 @@
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The protobuf encoding of Data is derived from the Go types of the data model
+// rather than generated from a .proto file, so that the two can't drift apart.
+// Each struct is a message, and the number of each field is declared by its
+// proto tag, e.g. `json:"name,omitempty" proto:"2"`. Numbers must never be
+// reused or changed once released, so that fields can be added and reordered
+// without breaking plugins built against an older schema.
+// Fields which can hold values of any type (interface{}), or which have their
+// own JSON decoding, are carried as JSON-encoded bytes. The resulting schema is
+// written by ProtoSchema, and checked in as toast.proto.
+
+//go:generate sh -c "go run ../cmd/toast schema -format=proto > toast.proto"
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// protoField describes how a struct field is carried in protobuf.
+type protoField struct {
+	index  int
+	num    uint64
+	name   string
+	typ    reflect.Type
+	asJSON bool
+}
+
+// protoFields returns the fields of the struct t which are carried in
+// protobuf, in the order of the struct.
+func protoFields(t reflect.Type) []protoField {
+	var fields []protoField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+
+		// a missing or invalid number is left 0, which is reported by the
+		// tests of the schema
+		num, _ := strconv.ParseUint(f.Tag.Get("proto"), 10, 29)
+		fields = append(fields, protoField{
+			index:  i,
+			num:    num,
+			name:   name,
+			typ:    f.Type,
+			asJSON: carriedAsJSON(f.Type),
+		})
+	}
+
+	return fields
+}
+
+// carriedAsJSON reports whether values of t (or the elements of a slice of t)
+// are encoded as JSON bytes.
+func carriedAsJSON(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+func marshalProto(d *Data) ([]byte, error) {
+	return appendMessage(nil, reflect.ValueOf(d).Elem())
+}
+
+func appendMessage(b []byte, v reflect.Value) ([]byte, error) {
+	var err error
+	for _, f := range protoFields(v.Type()) {
+		fv := v.Field(f.index)
+		if fv.Kind() == reflect.Slice {
+			for i := 0; i < fv.Len(); i++ {
+				b, err = appendValue(b, f.num, fv.Index(i), f.asJSON, true)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", f.name, err)
+				}
+			}
+			continue
+		}
+
+		b, err = appendValue(b, f.num, fv, f.asJSON, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+	}
+
+	return b, nil
+}
+
+// appendValue appends a single field value. Zero values are omitted, unless
+// they are elements of a repeated field or pointed to.
+func appendValue(b []byte, num uint64, v reflect.Value, asJSON, repeated bool) ([]byte, error) {
+	if !repeated && v.IsZero() {
+		return b, nil
+	}
+
+	if asJSON {
+		js, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(b, num, js), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return appendBytes(b, num, []byte(v.String())), nil
+
+	case reflect.Bool:
+		var x uint64
+		if v.Bool() {
+			x = 1
+		}
+		return binary.AppendUvarint(appendTag(b, num, wireVarint), x), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendUvarint(appendTag(b, num, wireVarint), uint64(v.Int())), nil
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return b, nil
+		}
+		return appendValue(b, num, v.Elem(), false, true)
+
+	case reflect.Struct:
+		msg, err := appendMessage(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(b, num, msg), nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map type %s", v.Type())
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var entry []byte
+			entry = appendBytes(entry, 1, []byte(k))
+			entry = appendBytes(entry, 2, []byte(v.MapIndex(reflect.ValueOf(k)).String()))
+			b = appendBytes(b, num, entry)
+		}
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func appendTag(b []byte, num uint64, wire int) []byte {
+	return binary.AppendUvarint(b, num<<3|uint64(wire))
+}
+
+func appendBytes(b []byte, num uint64, p []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+var errTruncated = errors.New("protobuf: truncated message")
+
+func unmarshalProto(b []byte, d *Data) error {
+	return readMessage(b, reflect.ValueOf(d).Elem())
+}
+
+func readMessage(b []byte, v reflect.Value) error {
+	fields := make(map[uint64]protoField)
+	for _, f := range protoFields(v.Type()) {
+		fields[f.num] = f
+	}
+
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		num, wire := tag>>3, int(tag&7)
+
+		var (
+			x       uint64
+			payload []byte
+		)
+		switch wire {
+		case wireVarint:
+			x, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			payload = b[n : n+int(l)]
+			b = b[n+int(l):]
+
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return errTruncated
+			}
+			b = b[size:]
+
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d", wire)
+		}
+
+		// skip fields unknown to this version of the data model
+		f, ok := fields[num]
+		if !ok {
+			continue
+		}
+
+		fv := v.Field(f.index)
+		if fv.Kind() == reflect.Slice {
+			elem := reflect.New(f.typ.Elem()).Elem()
+			if err := readValue(elem, wire, x, payload, f.asJSON); err != nil {
+				return fmt.Errorf("%s: %v", f.name, err)
+			}
+			fv.Set(reflect.Append(fv, elem))
+			continue
+		}
+
+		if err := readValue(fv, wire, x, payload, f.asJSON); err != nil {
+			return fmt.Errorf("%s: %v", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+func readValue(v reflect.Value, wire int, x uint64, payload []byte, asJSON bool) error {
+	if asJSON {
+		return json.Unmarshal(payload, v.Addr().Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(payload))
+
+	case reflect.Bool:
+		v.SetBool(x != 0)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(x))
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return readValue(v.Elem(), wire, x, payload, false)
+
+	case reflect.Struct:
+		return readMessage(payload, v)
+
+	case reflect.Map:
+		var entry struct {
+			Key   string `proto:"1"`
+			Value string `proto:"2"`
+		}
+		if err := readMessage(payload, reflect.ValueOf(&entry).Elem()); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(reflect.ValueOf(entry.Key), reflect.ValueOf(entry.Value))
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// ProtoSchema returns the protobuf schema of the ProtoEncoding of Data. Data
+// encoded with it is framed by the bytes "\x00TPB" and the ProtoVersion byte,
+// which precede the encoded Data message.
+func ProtoSchema() string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "// Code generated by toast schema -format=proto. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "syntax = \"proto3\";\n\npackage toast.v%d;\n", ProtoVersion)
+
+	for _, t := range protoMessages() {
+		fmt.Fprintf(buf, "\nmessage %s {\n", t.Name())
+		for _, f := range protoFields(t) {
+			typ, label := protoType(f)
+			if f.asJSON {
+				fmt.Fprintf(buf, "  // JSON-encoded %s\n", f.typ)
+			}
+			fmt.Fprintf(buf, "  %s%s %s = %d;\n", label, typ, f.name, f.num)
+		}
+		fmt.Fprintf(buf, "}\n")
+	}
+
+	return buf.String()
+}
+
+// protoMessages returns the struct types of the messages within Data, in the
+// order they are first used, starting with Data itself.
+func protoMessages() []reflect.Type {
+	var messages []reflect.Type
+	seen := make(map[reflect.Type]bool)
+	queue := []reflect.Type{reflect.TypeOf(Data{})}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		messages = append(messages, t)
+
+		for _, f := range protoFields(t) {
+			if elem := messageType(f.typ); elem != nil && !f.asJSON {
+				queue = append(queue, elem)
+			}
+		}
+	}
+
+	return messages
+}
+
+// protoFieldNum returns the protobuf field number of the named field of the
+// struct t.
+func protoFieldNum(t reflect.Type, name string) uint64 {
+	for _, f := range protoFields(t) {
+		if t.Field(f.index).Name == name {
+			return f.num
+		}
+	}
+
+	return 0
+}
+
+func protoType(f protoField) (typ, label string) {
+	t := f.typ
+	switch {
+	case t.Kind() == reflect.Map:
+		return "map<string, string>", ""
+	case t.Kind() == reflect.Slice:
+		label = "repeated "
+		t = t.Elem()
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() != reflect.Struct:
+		label = "optional "
+		t = t.Elem()
+	}
+	if f.asJSON {
+		return "bytes", label
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string", label
+	case reflect.Bool:
+		return "bool", label
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int64", label
+	case reflect.Ptr:
+		return t.Elem().Name(), label
+	}
+
+	return t.Name(), label
+}
+
+// messageType returns the struct type of a message field, if any.
+func messageType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		return t
+	}
+
+	return nil
+}
+
+func snakeCase(s string) string {
+	buf := &strings.Builder{}
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				buf.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestProtoFieldNumbers checks that every field of every message declares a
+// valid protobuf field number with its proto tag, which is unique within its
+// message.
+func TestProtoFieldNumbers(t *testing.T) {
+	for _, msg := range protoMessages() {
+		seen := make(map[uint64]string)
+		for _, f := range protoFields(msg) {
+			name := msg.Name() + "." + msg.Field(f.index).Name
+			switch {
+			case f.num == 0:
+				t.Errorf("%s: missing or invalid proto tag", name)
+			case f.num >= 19000 && f.num <= 19999:
+				t.Errorf("%s: field number %d is reserved by protobuf", name, f.num)
+			case seen[f.num] != "":
+				t.Errorf("%s: field number %d is already used by %s", name, f.num, seen[f.num])
+			default:
+				seen[f.num] = name
+			}
+		}
+	}
+}
+
+// TestProtoRoundTrip checks that data decoded from the ProtoEncoding is the
+// same as the data encoded, including the output base and parameters set on
+// each encoding.
+func TestProtoRoundTrip(t *testing.T) {
+	data, diags := Load([]string{"./..."}, Options{Dir: "../test", Types: true, Bodies: true})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	params := map[string]string{"table": "items", "out": "./internal/db"}
+	b, err := NewEncoder(data).Encode(ProtoEncoding+GzipSuffix, "/tmp/out", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := *data
+	want.OutputBase = "/tmp/out"
+	want.Parameters = params
+	if !reflect.DeepEqual(jsonValue(t, &want), jsonValue(t, got)) {
+		t.Errorf("decoded data differs from the data encoded")
+	}
+}
+
+// jsonValue returns v as decoded from its JSON encoding into an interface{},
+// so that the values of interface{} fields can be compared regardless of their
+// Go types.
+func jsonValue(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val interface{}
+	if err := json.Unmarshal(b, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	return val
+}
@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version is the version of toast.
+const Version = "0.2.0"
+
+// ProtocolVersion is the version of the protocol between toast and its
+// plugins, including the data model sent to them. It is incremented whenever
+// either changes in a way which plugins may need to detect.
+const ProtocolVersion = 1
+
+// Features of the data which are only collected when enabled, and which a
+// plugin may require.
+const (
+	FeatureTypes     = "types"     // type information, e.g. promoted fields and Data.Implements
+	FeaturePositions = "positions" // source positions
+	FeatureBodies    = "bodies"    // analysis of func and method bodies
+)
+
+// Features lists every feature supported by this version of toast.
+var Features = []string{FeatureTypes, FeaturePositions, FeatureBodies}
+
+// HandshakeEnv is the environment variable set by toast when it invokes a
+// plugin to request its Handshake. Rather than reading Data from stdin, the
+// plugin should write its Handshake as JSON to stdout and exit.
+const HandshakeEnv = "TOAST_HANDSHAKE"
+
+// Handshake describes the requirements and capabilities of a plugin.
+type Handshake struct {
+	Name string `json:"name,omitempty"`
+	// MinProtocolVersion is the oldest ProtocolVersion the plugin supports.
+	MinProtocolVersion int `json:"min_protocol_version,omitempty"`
+	// Features lists the features the plugin requires.
+	Features []string `json:"features,omitempty"`
+	// Encodings lists the encodings the plugin can decode. If empty, the
+	// plugin is assumed to only support JSONEncoding.
+	Encodings []string `json:"encodings,omitempty"`
+	// Filter selects the parts of the data the plugin uses, and only those
+	// are sent to it.
+	Filter *Filter `json:"filter,omitempty"`
+}
+
+// Check returns an error if a plugin with the handshake is incompatible with
+// this version of toast.
+func (h Handshake) Check() error {
+	if h.MinProtocolVersion > ProtocolVersion {
+		return fmt.Errorf(
+			"plugin requires protocol version %d, but toast %s supports version %d",
+			h.MinProtocolVersion, Version, ProtocolVersion,
+		)
+	}
+
+	var unsupported []string
+	for _, f := range h.Features {
+		if !contains(Features, f) {
+			unsupported = append(unsupported, f)
+		}
+	}
+	if unsupported != nil {
+		return fmt.Errorf(
+			"plugin requires features unsupported by toast %s: %s",
+			Version, strings.Join(unsupported, ", "),
+		)
+	}
+
+	return nil
+}
+
+// Encoding returns the encoding to use for the plugin, preferring enc if the
+// plugin supports it.
+func (h Handshake) Encoding(enc string) string {
+	if len(h.Encodings) == 0 {
+		return JSONEncoding
+	}
+	for _, e := range h.Encodings {
+		if e == enc {
+			return enc
+		}
+	}
+	for _, e := range h.Encodings {
+		if ValidEncoding(e) {
+			return e
+		}
+	}
+
+	return JSONEncoding
+}
+
+// Requires reports whether the plugin requires the feature.
+func (h Handshake) Requires(feature string) bool {
+	return contains(h.Features, feature)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResponseFDEnv is the environment variable set by toast to the number of a
+// file descriptor open for writing in the plugin, through which the plugin may
+// send a Response rather than writing files itself.
+const ResponseFDEnv = "TOAST_RESPONSE_FD"
+
+// Response is written as JSON by a plugin to the file descriptor named by
+// ResponseFDEnv.
+type Response struct {
+	// Files are written by toast under the plugin's output base.
+	Files []GeneratedFile `json:"files,omitempty"`
+	// Diagnostics are printed by toast along with those of other plugins. If
+	// any is an error, toast fails.
+	Diagnostics Diagnostics `json:"diagnostics,omitempty"`
+}
+
+// GeneratedFile is a file generated by a plugin.
+type GeneratedFile struct {
+	// Name is the slash-separated path of the file, relative to the plugin's
+	// output base.
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Write writes the file under the base directory, creating any directories
+// needed. The file is written to a temporary file which is then renamed, so
+// that the file is either replaced completely or left untouched.
+func (f GeneratedFile) Write(base string) error {
+	name := filepath.FromSlash(f.Name)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid generated file name, must be relative to the output base: %q", f.Name)
+	}
+
+	dst := filepath.Join(base, name)
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(f.Content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
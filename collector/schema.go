@@ -0,0 +1,305 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The JSON Schema of the messages between toast and its plugins is derived
+// from the Go types of the data model, in the same way as the protobuf schema,
+// so that plugins in other languages can generate their own bindings from it.
+// Reflection can't tell which types the interface{} fields of the model hold,
+// so those are described by unions. The schema is written by JSONSchema, and
+// checked in as toast.schema.json.
+
+//go:generate sh -c "go run ../cmd/toast schema -format=json > toast.schema.json"
+
+// jsonSchemaDraft is the version of JSON Schema the schema is written in.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema used to describe the data model.
+type jsonSchema struct {
+	Schema      string        `json:"$schema,omitempty"`
+	Ref         string        `json:"$ref,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Type        string        `json:"type,omitempty"`
+	Const       string        `json:"const,omitempty"`
+	Enum        []string      `json:"enum,omitempty"`
+	Properties  properties    `json:"properties,omitempty"`
+	Required    []string      `json:"required,omitempty"`
+	Items       *jsonSchema   `json:"items,omitempty"`
+	Additional  *jsonSchema   `json:"additionalProperties,omitempty"`
+	AnyOf       []*jsonSchema `json:"anyOf,omitempty"`
+	AllOf       []*jsonSchema `json:"allOf,omitempty"`
+	If          *jsonSchema   `json:"if,omitempty"`
+	Then        *jsonSchema   `json:"then,omitempty"`
+
+	Defs map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+type property struct {
+	name   string
+	schema *jsonSchema
+}
+
+// properties are the properties of an object, which are written in the order
+// of the fields of the struct they describe.
+type properties []property
+
+func (p properties) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.name)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := json.Marshal(prop.schema)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(schema)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// union describes the types of the values held by an interface{} field.
+type union struct {
+	// discriminator is the JSON name of the field of the same struct whose
+	// value selects the variant, if any
+	discriminator string
+	variants      []variant
+}
+
+type variant struct {
+	// when is the value of the discriminator selecting the variant
+	when string
+	typ  reflect.Type
+}
+
+var (
+	stringType    = reflect.TypeOf("")
+	stringVariant = variant{typ: stringType}
+)
+
+// unions describe the interface{} fields of the data model, keyed by the name
+// of the struct and the JSON name of the field.
+var unions = map[string]union{
+	"StructField.field_type": {variants: []variant{
+		stringVariant,
+		{typ: reflect.TypeOf(ValueType{})},
+	}},
+	"ValueType.value": {discriminator: "kind", variants: []variant{
+		{typeLit, stringType},
+		{sliceLit, stringType},
+		{arrayLit, stringType},
+		{mapLit, reflect.TypeOf(Map{})},
+		{chanLit, reflect.TypeOf(Channel{})},
+		{funcLit, reflect.TypeOf(Func{})},
+		{structLit, reflect.TypeOf(Struct{})},
+		{ifaceLit, reflect.TypeOf(Interface{})},
+	}},
+	"MapValue.value": {discriminator: "name", variants: []variant{
+		{literalLit, stringType},
+		{typeLit, stringType},
+		{arrayLit, stringType},
+		{sliceLit, stringType},
+		{interfaceLit, stringType},
+		{mapLit, reflect.TypeOf(Map{})},
+		{chanLit, reflect.TypeOf(Channel{})},
+		{funcLit, reflect.TypeOf(Func{})},
+	}},
+	"InterfaceField.value": {discriminator: "kind", variants: []variant{
+		{MethodField, reflect.TypeOf(Func{})},
+		{EmbedField, reflect.TypeOf(Interface{})},
+		{TypeTermField, reflect.TypeOf([]TypeTerm{})},
+	}},
+	"Channel.type": {variants: []variant{stringVariant}},
+	"Const.value":  {variants: []variant{stringVariant}},
+	"Var.value":    {variants: []variant{stringVariant}},
+}
+
+// enums list the values of string fields which are restricted to a set of
+// constants, keyed like unions.
+var enums = map[string][]string{
+	"Diagnostic.severity": {SeverityError, SeverityWarning, SeverityInfo},
+	"CommentBlock.kind":   {HeadingBlock, ParagraphBlock, CodeBlock, ListBlock},
+	"Filter.kinds":        {KindStruct, KindInterface, KindTypeDef, KindFunc, KindConst, KindVar},
+	"Handshake.encodings": encodings(),
+}
+
+// encodings lists every encoding, with and without compression.
+func encodings() []string {
+	var all []string
+	for _, enc := range []string{JSONEncoding, ProtoEncoding} {
+		all = append(all, enc, enc+GzipSuffix)
+	}
+
+	return all
+}
+
+// JSONSchema returns a JSON Schema describing the JSON encoding of the messages
+// between toast and its plugins: the Data sent to a plugin, and the Handshake
+// and Response it may send back, which are defined as Data, Handshake and
+// Response within its $defs.
+func JSONSchema() ([]byte, error) {
+	root := &jsonSchema{
+		Schema: jsonSchemaDraft,
+		Title:  "toast",
+		Description: fmt.Sprintf(
+			"Messages between toast %s and its plugins, for protocol version %d. "+
+				"Generated by toast schema -format=json. DO NOT EDIT.",
+			Version, ProtocolVersion,
+		),
+		Defs: make(map[string]*jsonSchema),
+	}
+	for _, v := range []interface{}{Data{}, Handshake{}, Response{}} {
+		root.AnyOf = append(root.AnyOf, schemaOf(reflect.TypeOf(v), root.Defs))
+	}
+
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// schemaOf returns the schema of values of t, adding the definitions of any
+// structs to defs.
+func schemaOf(t reflect.Type, defs map[string]*jsonSchema) *jsonSchema {
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), defs)
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{
+			Type:  "array",
+			Items: schemaOf(t.Elem(), defs),
+		}
+	case reflect.Map:
+		return &jsonSchema{
+			Type:       "object",
+			Additional: schemaOf(t.Elem(), defs),
+		}
+	case reflect.Struct:
+		ref := &jsonSchema{Ref: "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; !ok {
+			// the definition is added before its fields are described, so
+			// that recursive types refer back to it
+			def := &jsonSchema{Type: "object"}
+			defs[t.Name()] = def
+			structSchema(def, t, defs)
+		}
+		return ref
+	}
+
+	// any JSON value
+	return &jsonSchema{}
+}
+
+// structSchema describes the fields of the struct t in def.
+func structSchema(def *jsonSchema, t reflect.Type, defs map[string]*jsonSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			// the fields of embedded structs are promoted, as they are by
+			// encoding/json, e.g. those of the Func of an InterfaceMethod
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				structSchema(def, f.Type, defs)
+				continue
+			}
+			name = f.Name
+		}
+		if !contains(tag[1:], "omitempty") {
+			def.Required = append(def.Required, name)
+		}
+
+		key := t.Name() + "." + name
+		var schema *jsonSchema
+		if u, ok := unions[key]; ok {
+			schema = u.schema(name, def, defs)
+		} else {
+			schema = schemaOf(f.Type, defs)
+		}
+		if enum, ok := enums[key]; ok {
+			if schema.Items != nil {
+				schema.Items.Enum = enum
+			} else {
+				schema.Enum = enum
+			}
+		}
+		def.Properties = append(def.Properties, property{name, schema})
+	}
+}
+
+// schema returns the schema of the union's field, with the name, of the struct
+// def. The discriminator of a discriminated union, which must precede it in the
+// struct, is restricted to the values selecting its variants, and def requires
+// the field to hold the variant selected.
+func (u union) schema(name string, def *jsonSchema, defs map[string]*jsonSchema) *jsonSchema {
+	var (
+		schemas []*jsonSchema
+		seen    = make(map[reflect.Type]bool)
+		when    []string
+	)
+	for _, v := range u.variants {
+		schema := schemaOf(v.typ, defs)
+		if !seen[v.typ] {
+			seen[v.typ] = true
+			schemas = append(schemas, schema)
+		}
+		if u.discriminator == "" {
+			continue
+		}
+
+		when = append(when, v.when)
+		def.AllOf = append(def.AllOf, &jsonSchema{
+			If: &jsonSchema{
+				Properties: properties{{u.discriminator, &jsonSchema{Const: v.when}}},
+				Required:   []string{u.discriminator},
+			},
+			Then: &jsonSchema{
+				Properties: properties{{name, schema}},
+			},
+		})
+	}
+
+	for _, prop := range def.Properties {
+		if prop.name == u.discriminator {
+			prop.schema.Enum = when
+		}
+	}
+	if len(schemas) == 1 {
+		return schemas[0]
+	}
+
+	// the variants may overlap, e.g. Func and Interface have no required
+	// properties, so the value need only match any of them
+	return &jsonSchema{AnyOf: schemas}
+}
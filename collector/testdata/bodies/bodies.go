@@ -0,0 +1,52 @@
+// Package bodies declares funcs whose bodies are analyzed.
+package bodies
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+const prefix = "item:"
+
+var ErrEmpty = errors.New("empty")
+
+var count int
+
+// Key returns the key of the name.
+func Key(name string) (string, error) {
+	if name == "" {
+		return "", ErrEmpty
+	}
+	count++
+	return prefix + strings.ToLower(name), nil
+}
+
+type Item struct {
+	Name string
+}
+
+// Key returns the item's key.
+func (i *Item) Key() string {
+	k, _ := Key(i.Name)
+	return k
+}
+
+// Default returns the default item.
+func Default() Item {
+	return Item{Name: "default"}
+}
+
+// Limits returns fixed limits.
+func Limits() (int, float64, []string) {
+	return 10, 2.5, []string{"a", "b"}
+}
+
+// Sorted returns the names sorted, without crediting the calls and returns of
+// its closure to it.
+func Sorted(names []string) []string {
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j]) || false
+	})
+	return names
+}
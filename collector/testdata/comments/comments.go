@@ -0,0 +1,53 @@
+//go:build linux || darwin
+// +build linux darwin
+
+// Package comments declares types with every kind of comment.
+//
+// The package doc has a [Link] and a list:
+//   - one
+//   - two
+//
+//go:generate go run gen.go -out=comments_gen.go
+package comments
+
+// Doc is documented with annotations.
+//
+// @decl:export --formats=json,csv
+// @table name=docs
+//
+//go:generate stringer -type=Doc
+//go:noinline
+type Doc struct {
+	// Field is documented.
+	//go:generate field
+	Field string // and has a line comment
+
+	Other int /* and a block comment */
+}
+
+/*
+Block is documented with a block comment.
+
+	code block
+*/
+type Block struct{}
+
+type (
+	// Grouped is documented within a group.
+	Grouped int
+
+	// GroupedStruct is documented within a group.
+	GroupedStruct struct{} // with a line comment
+)
+
+// Method is documented.
+//
+// @deprecated use Other
+func (d Doc) Method() {}
+
+// a free-floating comment
+
+// Func is documented.
+//
+//go:noinline
+func Func() {}
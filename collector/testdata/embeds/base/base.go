@@ -0,0 +1,21 @@
+// Package base declares types embedded by other packages.
+package base
+
+import "time"
+
+// Record is embedded by every record.
+type Record struct {
+	ID      string
+	Created time.Time
+}
+
+// Key returns the record's key.
+func (r Record) Key() string { return r.ID }
+
+// Touch updates the record.
+func (r *Record) Touch() {}
+
+// Keyer has a key.
+type Keyer interface {
+	Key() string
+}
@@ -0,0 +1,40 @@
+// Package embeds declares types which embed others, within and across
+// packages.
+package embeds
+
+import (
+	"fmt"
+
+	"github.com/Fanatics/toast/collector/testdata/embeds/base"
+)
+
+// Named has a name.
+type Named struct {
+	Name string
+}
+
+// Label returns the name.
+func (n Named) Label() string { return n.Name }
+
+// Item embeds a type from another package, and a pointer to one in this
+// package.
+type Item struct {
+	base.Record
+	*Named
+	Price int
+}
+
+// String implements fmt.Stringer.
+func (i Item) String() string { return i.Name }
+
+// Labeler has a label.
+type Labeler interface {
+	Label() string
+}
+
+// Entity embeds interfaces from this and other packages.
+type Entity interface {
+	base.Keyer
+	Labeler
+	fmt.Stringer
+}
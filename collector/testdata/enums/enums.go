@@ -0,0 +1,47 @@
+// Package enums declares enumerations of typed constants.
+package enums
+
+// Color is a color.
+type Color int
+
+// The colors.
+const (
+	Red   Color = iota // the first color
+	Green              // the second color
+	Blue
+)
+
+// String returns the name of the color.
+func (c Color) String() string {
+	switch c {
+	case Red:
+		return "red"
+	case Green:
+		return "green"
+	}
+	return "blue"
+}
+
+// Size is a size, by name.
+type Size string
+
+const (
+	Small  Size = "S"
+	Medium Size = "M"
+	Large  Size = "L"
+)
+
+const (
+	bit0, mask0 = 1 << iota, 1<<iota - 1
+	bit1, mask1
+)
+
+// Default is the default color.
+var Default = Blue
+
+var (
+	all    = []Color{Red, Green, Blue}
+	names  = map[Color]string{Red: "red"}
+	zero   Color
+	maxVal = 1e6
+)
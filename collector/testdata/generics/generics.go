@@ -0,0 +1,63 @@
+// Package generics declares generic types and funcs.
+package generics
+
+// Number is a constraint satisfied by integer and float types.
+type Number interface {
+	~int | ~int64 | float64
+}
+
+// Stringer is a constraint with both a method and a type set.
+type Stringer interface {
+	~string
+	String() string
+}
+
+// Pair holds two values of any types.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// List is a linked list.
+type List[T any] struct {
+	next  *List[T]
+	Value T
+}
+
+// Sum returns the sum of the numbers.
+func Sum[T Number](nums ...T) T {
+	var sum T
+	for _, n := range nums {
+		sum += n
+	}
+	return sum
+}
+
+// Map applies fn to each value.
+func Map[T, U any](vals []T, fn func(T) U) []U {
+	out := make([]U, 0, len(vals))
+	for _, v := range vals {
+		out = append(out, fn(v))
+	}
+	return out
+}
+
+// Len returns the length of the list, naming its type parameter differently
+// from its declaration.
+func (l *List[E]) Len() int {
+	n := 0
+	for ; l != nil; l = l.next {
+		n++
+	}
+	return n
+}
+
+// Second returns the value of the pair, ignoring the type of its key.
+func (p Pair[_, V]) Second() V { return p.Value }
+
+// Has reports whether the set holds v, declared in a different file from its
+// type.
+func (s Set[T]) Has(v T) bool {
+	_, ok := s[v]
+	return ok
+}
@@ -0,0 +1,15 @@
+package generics
+
+// Set is a set of comparable values.
+type Set[T comparable] map[T]struct{}
+
+// Swap returns the pair with its key and value swapped, declared in a
+// different file from its type.
+func (p Pair[K, V]) Swap() Pair[V, K] {
+	return Pair[V, K]{Key: p.Value, Value: p.Key}
+}
+
+// Push adds a value to the front of the list.
+func (l *List[T]) Push(v T) *List[T] {
+	return &List[T]{next: l, Value: v}
+}
@@ -0,0 +1,57 @@
+// Package types declares struct fields of every shape of type.
+package types
+
+import (
+	"io"
+	"time"
+)
+
+type Shapes struct {
+	Ident      string
+	Selector   time.Duration
+	Pointer    *int
+	PointerSel *time.Time
+	Slice      []byte
+	SliceOfPtr []*Shapes
+	Array      [4]int
+	PtrArray   *[2]string
+	Ellipsis   func(args ...string)
+
+	MapOfIdent     map[string]int
+	MapOfSelector  map[string]time.Time
+	MapOfSlice     map[string][]int
+	MapOfArray     map[string][3]int
+	MapOfMap       map[string]map[int]bool
+	MapOfFunc      map[string]func(int) error
+	MapOfInterface map[string]interface{}
+	MapOfChan      map[string]chan int
+	MapOfPointer   map[string]*Shapes
+
+	Chan     chan int
+	RecvChan <-chan string
+	SendChan chan<- error
+	ChanSel  chan time.Time
+
+	Func      func()
+	FuncNamed func(ctx string, n int) (ok bool, err error)
+
+	Iface       interface{}
+	IfaceMethod interface{ Close() error }
+	Anonymous   struct {
+		Nested string `json:"nested"`
+		Deeper struct {
+			Value int
+		}
+	}
+
+	io.Reader
+	*time.Location
+
+	Tagged     string `json:"tagged,omitempty" db:"tagged"`
+	A, B       int
+	unexported bool
+}
+
+type Ident string
+
+type Pointer *Shapes
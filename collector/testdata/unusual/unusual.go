@@ -0,0 +1,82 @@
+// Package unusual declares valid Go in shapes which the collector once failed
+// to handle.
+package unusual
+
+import "time"
+
+const N = 4
+
+// List is a generic linked list.
+type List[T any] struct {
+	head *node[T]
+	Len  int
+}
+
+type node[T any] struct {
+	next  *node[T]
+	value T
+}
+
+// Push adds v to the front of the list.
+func (l *List[T]) Push(v T) {
+	l.head = &node[T]{next: l.head, value: v}
+	l.Len++
+}
+
+// Front returns the first value.
+func (l List[T]) Front() T { return l.head.value }
+
+// Pair is a generic pair.
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+// Swap swaps the pair.
+func (p *Pair[K, V]) Swap() {}
+
+// Set is a set.
+type Set[T comparable] map[T]struct{}
+
+// Has reports whether v is in the set.
+func (s Set[T]) Has(v T) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// Getter gets a value.
+type Getter[T any] interface {
+	Get() T
+}
+
+// IntGetter embeds an instantiated generic interface.
+type IntGetter interface {
+	Getter[int]
+}
+
+// Bytes is a constraint with a single type term.
+type Bytes interface {
+	[]byte
+}
+
+// Shapes declares fields the collector once panicked or silently failed on.
+type Shapes struct {
+	Computed    [N + 1]int
+	Times       []time.Time
+	Lists       []List[int]
+	Matrix      [][]float64
+	Generic     List[string]
+	GenericPtr  *Pair[string, int]
+	ByGeneric   map[string]List[int]
+	ByArray     map[[2]int]string
+	Set         map[string]struct{}
+	Computed2   map[string][N * 2]int
+	Parenthesis (int)
+	ParenMap    map[string](int)
+}
+
+// Handler handles events.
+type Handler func(time.Time) error
+
+// Alias is an alias of an instantiated generic type.
+type Alias = List[int]
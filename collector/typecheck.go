@@ -0,0 +1,426 @@
+package collector
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// TypeInfo holds the result of type-checking a single package. It is used by
+// the FileCollector to resolve information which cannot be determined from the
+// syntax of a file alone, such as declarations made in other packages.
+type TypeInfo struct {
+	Pkg  *types.Package
+	Info *types.Info
+	// Errors contains any (soft) errors reported while type-checking. The
+	// package is still checked as completely as possible when they occur.
+	Errors []error
+
+	checker *Checker
+}
+
+// Checker type-checks packages parsed by toast. Imports of other packages
+// which were added to the Checker are checked from the same syntax trees, so
+// that their objects are shared, and all other imports are loaded from source.
+type Checker struct {
+	fset     *token.FileSet
+	files    map[string][]*ast.File
+	checked  map[string]*TypeInfo
+	checking map[string]bool
+	fallback types.Importer
+	// methods indexes the interface methods declared in the added files by
+	// the position of their names, once built by methodField
+	methods map[token.Pos]*ast.Field
+}
+
+// NewChecker returns a Checker for files parsed using fset.
+func NewChecker(fset *token.FileSet) *Checker {
+	return &Checker{
+		fset:     fset,
+		files:    make(map[string][]*ast.File),
+		checked:  make(map[string]*TypeInfo),
+		checking: make(map[string]bool),
+		fallback: importer.ForCompiler(fset, "source", nil),
+	}
+}
+
+// Add registers the files of the package with the given import path.
+func (c *Checker) Add(path string, files []*ast.File) {
+	c.files[path] = append(c.files[path], files...)
+}
+
+// Check type-checks the package with the given import path, returning nil if
+// no such package was added to the Checker.
+func (c *Checker) Check(path string) *TypeInfo {
+	if ti, ok := c.checked[path]; ok {
+		return ti
+	}
+
+	files, ok := c.files[path]
+	if !ok {
+		return nil
+	}
+
+	ti := &TypeInfo{
+		Info: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		},
+		checker: c,
+	}
+	conf := types.Config{
+		Importer: c,
+		Error: func(err error) {
+			ti.Errors = append(ti.Errors, err)
+		},
+	}
+
+	c.checking[path] = true
+	// errors are accumulated by the config's Error func, and the returned
+	// package is complete enough to be used regardless
+	ti.Pkg, _ = conf.Check(path, c.fset, files, ti.Info)
+	delete(c.checking, path)
+
+	c.checked[path] = ti
+	return ti
+}
+
+// Import implements types.Importer.
+func (c *Checker) Import(path string) (*types.Package, error) {
+	if _, ok := c.files[path]; !ok {
+		return c.fallback.Import(path)
+	}
+
+	if c.checking[path] {
+		return nil, fmt.Errorf("import cycle through package %s", path)
+	}
+
+	return c.Check(path).Pkg, nil
+}
+
+// methodField returns the field declaring the interface method fn, or nil if
+// it isn't declared in the files added to the Checker, e.g. because it's
+// declared in a package loaded from source.
+func (c *Checker) methodField(fn *types.Func) *ast.Field {
+	if c.methods == nil {
+		c.methods = make(map[token.Pos]*ast.Field)
+		for _, files := range c.files {
+			for _, file := range files {
+				ast.Inspect(file, func(n ast.Node) bool {
+					iface, ok := n.(*ast.InterfaceType)
+					if !ok {
+						return true
+					}
+					for _, field := range iface.Methods.List {
+						for _, name := range field.Names {
+							c.methods[name.Pos()] = field
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	return c.methods[fn.Pos()]
+}
+
+// Implementations computes which of the named concrete types declared in the
+// packages checked so far satisfy which of the interfaces declared in them.
+// Both value and pointer receivers are considered, as well as methods obtained
+// through embedded interfaces and embedded fields.
+func (c *Checker) Implementations() []Implementation {
+	var paths []string
+	for path := range c.checked {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var concrete, ifaces []*types.TypeName
+	for _, path := range paths {
+		pkg := c.checked[path].Pkg
+		if pkg == nil {
+			continue
+		}
+		for _, name := range pkg.Scope().Names() {
+			obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() {
+				continue
+			}
+			// generic types must be instantiated before they can satisfy an
+			// interface, or be used as one
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+
+			iface, ok := obj.Type().Underlying().(*types.Interface)
+			switch {
+			case !ok:
+				concrete = append(concrete, obj)
+			// the empty interface, and constraints which may only be used as
+			// type parameters, would only add noise
+			case iface.NumMethods() > 0 && iface.IsMethodSet():
+				ifaces = append(ifaces, obj)
+			}
+		}
+	}
+
+	var impls []Implementation
+	for _, typ := range concrete {
+		for _, iface := range ifaces {
+			it := iface.Type().Underlying().(*types.Interface)
+			impl := Implementation{
+				Type:      types.TypeString(typ.Type(), nil),
+				Interface: types.TypeString(iface.Type(), nil),
+			}
+			switch {
+			case types.Implements(typ.Type(), it):
+			case types.Implements(types.NewPointer(typ.Type()), it):
+				impl.Indirect = true
+			default:
+				continue
+			}
+			impls = append(impls, impl)
+		}
+	}
+
+	return impls
+}
+
+// promoted returns the fields and methods promoted to the named struct type
+// from its embedded fields, following the depth and ambiguity rules of the Go
+// spec: only the shallowest, unambiguous selector for a name is promoted, and
+// names declared directly on the struct are never promoted.
+func (t *TypeInfo) promoted(name string) []Promoted {
+	if t == nil || t.Pkg == nil {
+		return nil
+	}
+
+	obj, ok := t.Pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+		return nil
+	}
+
+	var (
+		promoted []Promoted
+		seen     = make(map[string]bool)
+	)
+	for _, cand := range embeddedNames(obj.Type(), make(map[types.Type]bool)) {
+		if seen[cand] {
+			continue
+		}
+		seen[cand] = true
+
+		sel, index, indirect := types.LookupFieldOrMethod(obj.Type(), true, t.Pkg, cand)
+		if sel == nil || len(index) < 2 {
+			// either ambiguous, inaccessible, or declared on the struct itself
+			continue
+		}
+
+		p := Promoted{
+			Name:       sel.Name(),
+			IsExported: sel.Exported(),
+			Depth:      len(index) - 1,
+			Indirect:   indirect,
+		}
+
+		// follow the embedded fields through which the selector is reached
+		typ := obj.Type()
+		for _, i := range index[:len(index)-1] {
+			strct, ok := deref(typ).Underlying().(*types.Struct)
+			if !ok {
+				break
+			}
+			field := strct.Field(i)
+			p.Path = append(p.Path, field.Name())
+			typ = field.Type()
+		}
+
+		switch sel := sel.(type) {
+		case *types.Var:
+			p.Type = types.TypeString(sel.Type(), packageName(t.Pkg))
+			p.Origin = types.TypeString(deref(typ), nil)
+
+		case *types.Func:
+			sig := sel.Type().(*types.Signature)
+			p.IsMethod = true
+			p.Type = types.TypeString(sig, packageName(t.Pkg))
+			if recv := sig.Recv(); recv != nil {
+				_, p.PointerReceiver = recv.Type().(*types.Pointer)
+				p.Origin = types.TypeString(deref(recv.Type()), nil)
+			}
+		}
+
+		promoted = append(promoted, p)
+	}
+
+	return promoted
+}
+
+// allMethods returns the complete method set of the named interface type,
+// including the methods of any interfaces embedded within it at any depth,
+// whether they are declared in the same package or another. The doc and line
+// comments of each method are those of its declaration, if it was declared in
+// one of the packages added to the Checker.
+func (t *TypeInfo) allMethods(name string) []InterfaceMethod {
+	if t == nil || t.Pkg == nil {
+		return nil
+	}
+
+	obj, ok := t.Pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+
+	origins := make(map[*types.Func]string)
+	methodOrigins(obj.Type(), "", origins, make(map[types.Type]bool))
+
+	var methods []InterfaceMethod
+	for i := 0; i < iface.NumMethods(); i++ {
+		fn := iface.Method(i)
+		sig := fn.Type().(*types.Signature)
+		m := InterfaceMethod{
+			Func: Func{
+				Name:       fn.Name(),
+				IsExported: fn.Exported(),
+				Params:     tupleValues(sig.Params(), sig.Variadic(), packageName(t.Pkg)),
+				Results:    tupleValues(sig.Results(), false, packageName(t.Pkg)),
+			},
+			Origin: origins[fn],
+		}
+		if t.checker != nil {
+			if field := t.checker.methodField(fn); field != nil {
+				m.Doc = normalizeComment(field.Doc)
+				m.Comment = normalizeComment(field.Comment)
+			}
+		}
+		methods = append(methods, m)
+	}
+
+	return methods
+}
+
+// methodOrigins maps the methods declared explicitly by the interface typ, and
+// by the interfaces embedded within it, to the qualified name of the nearest
+// named interface which declares them.
+func methodOrigins(typ types.Type, origin string, origins map[*types.Func]string, seen map[types.Type]bool) {
+	if seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	if named, ok := typ.(*types.Named); ok {
+		origin = types.TypeString(named, nil)
+	}
+	iface, ok := typ.Underlying().(*types.Interface)
+	if !ok {
+		return
+	}
+
+	for i := 0; i < iface.NumExplicitMethods(); i++ {
+		origins[iface.ExplicitMethod(i)] = origin
+	}
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		methodOrigins(iface.EmbeddedType(i), origin, origins, seen)
+	}
+}
+
+// tupleValues converts the params or results of a signature to Values, which
+// are formatted the same as those collected from source.
+func tupleValues(tuple *types.Tuple, variadic bool, qf types.Qualifier) []Value {
+	if tuple == nil {
+		return nil
+	}
+
+	var vals []Value
+	for i := 0; i < tuple.Len(); i++ {
+		v := tuple.At(i)
+		val := Value{
+			Type: types.TypeString(v.Type(), qf),
+		}
+		if v.Name() != "" {
+			name := v.Name()
+			val.Name = &name
+		}
+		if variadic && i == tuple.Len()-1 {
+			if slice, ok := v.Type().(*types.Slice); ok {
+				val.Type = ellipsis + types.TypeString(slice.Elem(), qf)
+			}
+		}
+		vals = append(vals, val)
+	}
+
+	return vals
+}
+
+// embeddedNames returns the names of all fields and methods reachable through
+// the embedded fields of typ, at any depth.
+func embeddedNames(typ types.Type, seen map[types.Type]bool) []string {
+	strct, ok := deref(typ).Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	for i := 0; i < strct.NumFields(); i++ {
+		field := strct.Field(i)
+		if !field.Embedded() {
+			continue
+		}
+
+		embedded := deref(field.Type())
+		if seen[embedded] {
+			continue
+		}
+		seen[embedded] = true
+
+		if s, ok := embedded.Underlying().(*types.Struct); ok {
+			for j := 0; j < s.NumFields(); j++ {
+				names = append(names, s.Field(j).Name())
+			}
+		}
+
+		mset := types.NewMethodSet(types.NewPointer(embedded))
+		if _, ok := embedded.Underlying().(*types.Interface); ok {
+			mset = types.NewMethodSet(embedded)
+		}
+		for j := 0; j < mset.Len(); j++ {
+			names = append(names, mset.At(j).Obj().Name())
+		}
+
+		names = append(names, embeddedNames(embedded, seen)...)
+	}
+
+	return names
+}
+
+func deref(typ types.Type) types.Type {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+
+	return typ
+}
+
+// packageName qualifies types from packages other than pkg by their package
+// name, matching the selector expressions found in source, e.g. base.Data.
+func packageName(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
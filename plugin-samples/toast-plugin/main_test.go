@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Fanatics/toast/plugin"
+	"github.com/Fanatics/toast/plugintest"
+)
+
+func TestGenerate(t *testing.T) {
+	resp := plugintest.InProcess(p, plugin.Func(generate)).Run(t, "testdata/fixture", nil)
+	plugintest.Golden(t, "testdata/golden", resp.Files)
+}
+
+func TestGenerateCommand(t *testing.T) {
+	exe := plugintest.Build(t, ".")
+	resp := plugintest.Command(exe).WithHandshake().Run(t, "testdata/fixture", nil)
+	plugintest.Golden(t, "testdata/golden", resp.Files)
+}
@@ -0,0 +1,6 @@
+package base
+
+// Data is common to every record.
+type Data struct {
+	ID string `json:"id"`
+}
@@ -0,0 +1,10 @@
+package fixture
+
+import "github.com/Fanatics/toast/plugin-samples/toast-plugin/testdata/fixture/base"
+
+// Item is an item for sale.
+type Item struct {
+	base.Data
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// Report records diagnostics to be sent to toast when the Plugin completes. If
+// any is an error, the Plugin fails: none of its files are written, and it
+// exits with a non-zero status. The Source of each diagnostic is set to the
+// Plugin's name if empty.
+func (p *Plugin) Report(diags ...collector.Diagnostic) {
+	for _, d := range diags {
+		if d.Source == "" {
+			d.Source = p.name
+		}
+		if d.Severity == "" {
+			d.Severity = collector.SeverityError
+		}
+		p.diagnostics = append(p.diagnostics, d)
+	}
+}
+
+// Errorf reports an error related to the declaration decl, e.g.
+// Item.Dimensions, at pos, either of which may be empty.
+func (p *Plugin) Errorf(pos *collector.Position, decl, format string, args ...interface{}) {
+	p.Report(collector.Diagnostic{
+		Severity: collector.SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+		Decl:     decl,
+	})
+}
+
+// Warnf reports a warning related to the declaration decl at pos, either of
+// which may be empty. Warnings don't cause the Plugin to fail.
+func (p *Plugin) Warnf(pos *collector.Position, decl, format string, args ...interface{}) {
+	p.Report(collector.Diagnostic{
+		Severity: collector.SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+		Decl:     decl,
+	})
+}
+
+// diagnostic converts an error returned to the Plugin into a Diagnostic,
+// unless it already is one.
+func diagnostic(err error) collector.Diagnostic {
+	var d collector.Diagnostic
+	if errors.As(err, &d) {
+		return d
+	}
+
+	return collector.Diagnostic{
+		Severity: collector.SeverityError,
+		Message:  err.Error(),
+	}
+}
+
+// respond sends the files and diagnostics to toast in a collector.Response,
+// if toast provided a response descriptor. Otherwise, the diagnostics are
+// written to stderr and the files are written under the output base.
+func (p *Plugin) respond(files []collector.GeneratedFile) {
+	if fd := os.Getenv(collector.ResponseFDEnv); fd != "" {
+		resp := collector.Response{
+			Files:       files,
+			Diagnostics: p.diagnostics,
+		}
+		if err := writeResponse(fd, resp); err != nil {
+			p.Report(diagnostic(err))
+			p.printDiagnostics()
+		}
+		return
+	}
+
+	if len(files) > 0 && strings.TrimSpace(p.outputBase) == "" {
+		p.Report(diagnostic(errors.New("no output base to write files under")))
+	} else {
+		for _, f := range files {
+			if err := f.Write(p.outputBase); err != nil {
+				p.Report(diagnostic(err))
+				break
+			}
+		}
+	}
+	p.printDiagnostics()
+}
+
+func (p *Plugin) printDiagnostics() {
+	p.diagnostics.Sort()
+	for _, d := range p.diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+}
+
+// exit exits with a non-zero status if any error was reported.
+func (p *Plugin) exit() {
+	if p.diagnostics.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+func writeResponse(fd string, resp collector.Response) error {
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", collector.ResponseFDEnv, fd)
+	}
+
+	w := os.NewFile(uintptr(n), "response")
+	if w == nil {
+		return fmt.Errorf("invalid %s: %s", collector.ResponseFDEnv, fd)
+	}
+	defer w.Close()
+
+	return json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// generatedHeader marks files written by plugins as generated, following the
+// convention recognized by Go tooling (see https://go.dev/s/generatedcode).
+const generatedHeader = "Code generated by toast-plugin. DO NOT EDIT."
+
+// commentPrefixes maps file extensions to the line comment syntax used for
+// their header. Files with other extensions are written without a header.
+var commentPrefixes = map[string]string{
+	".go":    "//",
+	".proto": "//",
+	".js":    "//",
+	".ts":    "//",
+	".java":  "//",
+	".swift": "//",
+	".kt":    "//",
+	".c":     "//",
+	".h":     "//",
+	".sql":   "--",
+	".py":    "#",
+	".rb":    "#",
+	".sh":    "#",
+	".yaml":  "#",
+	".yml":   "#",
+	".toml":  "#",
+}
+
+// File is a file generated by a Plugin, created with NewFile. Its content is
+// written to its buffer, and once the Plugin's Func returns successfully the
+// file is completed and written under the output base.
+type File struct {
+	bytes.Buffer
+	name    string
+	sources []string
+	imports *Imports
+}
+
+// NewFile returns a File to be written at the slash-separated path name,
+// relative to the output base, e.g. "db/items.go".
+func (p *Plugin) NewFile(name string) *File {
+	f := &File{name: name}
+	p.files = append(p.files, f)
+	return f
+}
+
+// Name returns the path of the file, relative to the output base.
+func (f *File) Name() string {
+	return f.name
+}
+
+// AddSource records the source files from which the file was generated, e.g.
+// collector.File.Name, which are referenced in the file's header.
+func (f *File) AddSource(sources ...string) *File {
+	for _, src := range sources {
+		if !contains(f.sources, src) {
+			f.sources = append(f.sources, src)
+		}
+	}
+
+	return f
+}
+
+// Imports returns the Imports of a Go file of the package with the import path
+// pkgPath. Its import declaration is added to the file's content when the file
+// is completed, so the file's buffer should only hold the package clause and
+// declarations.
+func (f *File) Imports(pkgPath string) *Imports {
+	if f.imports == nil {
+		f.imports = NewImports(pkgPath)
+	}
+
+	return f.imports
+}
+
+// Content returns the completed content of the file: its header followed by
+// the contents of its buffer, and for Go files, with its imports fixed and
+// gofmt applied.
+func (f *File) Content() ([]byte, error) {
+	ext := path.Ext(f.name)
+	content := append(f.header(commentPrefixes[ext]), f.Bytes()...)
+	if ext != ".go" {
+		return content, nil
+	}
+
+	if f.imports != nil {
+		var err error
+		content, err = f.imports.Render(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+	}
+	fmtd, err := Goimports(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", f.name, err)
+	}
+
+	return fmtd, nil
+}
+
+func (f *File) header(prefix string) []byte {
+	if prefix == "" {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s\n", prefix, generatedHeader)
+	for _, src := range f.sources {
+		fmt.Fprintf(buf, "%s source: %s\n", prefix, src)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes()
+}
+
+// generateFiles completes the files created by the Plugin.
+func (p *Plugin) generateFiles() ([]collector.GeneratedFile, error) {
+	var (
+		files []collector.GeneratedFile
+		seen  = make(map[string]bool)
+	)
+	for _, f := range p.files {
+		if seen[path.Clean(f.name)] {
+			return nil, fmt.Errorf("%s: file created more than once", f.name)
+		}
+		seen[path.Clean(f.name)] = true
+
+		content, err := f.Content()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, collector.GeneratedFile{
+			Name:    f.name,
+			Content: string(content),
+		})
+	}
+
+	return files, nil
+}
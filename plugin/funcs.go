@@ -0,0 +1,421 @@
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// FuncMap returns the functions available to every template executed by
+// OutputTemplate and OutputTemplateHTML. The value a function operates on is
+// its last argument, so that it can be piped, e.g. {{ .Name | snake }}.
+//
+// Naming conventions:
+//
+//	snake "ItemID"         // item_id
+//	upperSnake "ItemID"    // ITEM_ID
+//	kebab "ItemID"         // item-id
+//	camel "item_id"        // itemId
+//	pascal "item_id"       // ItemId
+//	goName "item_id"       // ItemID
+//	plural "Category"      // Categories
+//	singular "Categories"  // Category
+//
+// Types, tags and annotations:
+//
+//	goType $field               // []*base.Data, see GoType
+//	tag "json" $field           // item_id,omitempty
+//	tagName "json" $field       // item_id, or the field's name if it has no tag
+//	annotations $doc            // []collector.Annotation
+//	annotation "decl:export" $doc     // *collector.Annotation, or nil
+//	hasAnnotation "decl:export" $doc  // bool
+//
+// Text:
+//
+//	indent 1 $text      // each line indented by a tab
+//	prefix "# " $text   // each line prefixed
+//	wrap 80 $text       // words wrapped at 80 columns
+//	comment $text       // wrapped Go line comments
+//
+// as well as lower, upper, trim, trimPrefix, trimSuffix, replace, split, join,
+// contains, hasPrefix, hasSuffix and repeat, which are those of the strings
+// package with their arguments reordered to be piped.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"snake":      snake,
+		"upperSnake": upperSnake,
+		"kebab":      kebab,
+		"camel":      camel,
+		"pascal":     pascal,
+		"goName":     goName,
+		"plural":     plural,
+		"singular":   singular,
+
+		"goType":        GoType,
+		"tag":           tag,
+		"tagName":       tagName,
+		"annotations":   annotations,
+		"annotation":    annotation,
+		"hasAnnotation": hasAnnotation,
+
+		"indent":  indent,
+		"prefix":  prefixLines,
+		"wrap":    wrap,
+		"comment": comment,
+
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       func(sep string, elems []string) string { return strings.Join(elems, sep) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+	}
+}
+
+// words splits an identifier into its words, at case changes and any
+// characters other than letters and digits, e.g. HTTP, Server, ID for
+// HTTPServer_ID.
+func words(s string) []string {
+	var (
+		words []string
+		word  []rune
+		runes = []rune(s)
+	)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			lowerNext := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && lowerNext) {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	return words
+}
+
+func snake(s string) string {
+	return strings.ToLower(strings.Join(words(s), "_"))
+}
+
+func upperSnake(s string) string {
+	return strings.ToUpper(strings.Join(words(s), "_"))
+}
+
+func kebab(s string) string {
+	return strings.ToLower(strings.Join(words(s), "-"))
+}
+
+func camel(s string) string {
+	p := pascal(s)
+	if p == "" {
+		return p
+	}
+
+	r := []rune(p)
+	return string(unicode.ToLower(r[0])) + string(r[1:])
+}
+
+func pascal(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		b.WriteString(title(strings.ToLower(w)))
+	}
+
+	return b.String()
+}
+
+// goName converts s to an exported Go identifier, following the Go convention
+// for initialisms, e.g. ItemID for item_id.
+func goName(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(title(strings.ToLower(w)))
+	}
+
+	return b.String()
+}
+
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r := []rune(s)
+	return string(unicode.ToUpper(r[0])) + string(r[1:])
+}
+
+// initialisms are written in a consistent case in Go identifiers, see
+// https://go.dev/wiki/CodeReviewComments#initialisms.
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true,
+	"CSV": true, "DNS": true, "EOF": true, "GUID": true, "HTML": true,
+	"HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"LHS": true, "QPS": true, "RAM": true, "RHS": true, "RPC": true,
+	"SKU": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true,
+	"TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true,
+	"UID": true, "URI": true, "URL": true, "UTF8": true, "UUID": true,
+	"VM": true, "XML": true, "XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// plural returns the English plural of the last word of s, e.g. ItemCategories
+// for ItemCategory.
+func plural(s string) string {
+	stem, last := lastWord(s)
+	if last == "" {
+		return s
+	}
+
+	lower := strings.ToLower(last)
+	if uncountable[lower] {
+		return s
+	}
+	if p, ok := irregularPlurals[lower]; ok {
+		return stem + matchCase(last, p)
+	}
+
+	switch {
+	case hasAnySuffix(lower, "s", "x", "z", "ch", "sh"):
+		lower += "es"
+	case strings.HasSuffix(lower, "y") && !hasAnySuffix(lower, "ay", "ey", "iy", "oy", "uy"):
+		lower = strings.TrimSuffix(lower, "y") + "ies"
+	default:
+		lower += "s"
+	}
+
+	return stem + matchCase(last, lower)
+}
+
+// singular returns the English singular of the last word of s, e.g.
+// ItemCategory for ItemCategories.
+func singular(s string) string {
+	stem, last := lastWord(s)
+	if last == "" {
+		return s
+	}
+
+	lower := strings.ToLower(last)
+	if uncountable[lower] {
+		return s
+	}
+	for sing, p := range irregularPlurals {
+		if lower == p {
+			return stem + matchCase(last, sing)
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(lower) > 3:
+		lower = strings.TrimSuffix(lower, "ies") + "y"
+	case hasAnySuffix(lower, "sses", "uses", "xes", "zes", "ches", "shes"):
+		lower = strings.TrimSuffix(lower, "es")
+	case hasAnySuffix(lower, "ss", "us", "is"):
+	case strings.HasSuffix(lower, "s"):
+		lower = strings.TrimSuffix(lower, "s")
+	}
+
+	return stem + matchCase(last, lower)
+}
+
+// lastWord splits s before its last word.
+func lastWord(s string) (stem, last string) {
+	ws := words(s)
+	if len(ws) == 0 || !strings.HasSuffix(s, ws[len(ws)-1]) {
+		return s, ""
+	}
+
+	last = ws[len(ws)-1]
+	return strings.TrimSuffix(s, last), last
+}
+
+// matchCase converts word to the case of orig: upper, title or lower.
+func matchCase(orig, word string) string {
+	switch {
+	case len(orig) > 1 && strings.ToUpper(orig) == orig:
+		return strings.ToUpper(word)
+	case unicode.IsUpper([]rune(orig)[0]):
+		return title(word)
+	}
+
+	return word
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var irregularPlurals = map[string]string{
+	"child":  "children",
+	"person": "people",
+	"man":    "men",
+	"woman":  "women",
+	"mouse":  "mice",
+	"goose":  "geese",
+	"foot":   "feet",
+	"tooth":  "teeth",
+	"index":  "indices",
+	"matrix": "matrices",
+	"vertex": "vertices",
+	"datum":  "data",
+	"leaf":   "leaves",
+	"life":   "lives",
+	"knife":  "knives",
+	"wife":   "wives",
+	"half":   "halves",
+	"shelf":  "shelves",
+	"wolf":   "wolves",
+	"hero":   "heroes",
+	"potato": "potatoes",
+	"tomato": "tomatoes",
+}
+
+var uncountable = map[string]bool{
+	"data":        true,
+	"equipment":   true,
+	"fish":        true,
+	"information": true,
+	"info":        true,
+	"metadata":    true,
+	"money":       true,
+	"news":        true,
+	"series":      true,
+	"sheep":       true,
+	"species":     true,
+}
+
+// tag returns the value associated with key in the field's tag.
+func tag(key string, field collector.StructField) string {
+	return reflect.StructTag(strings.Trim(field.Tag, "`")).Get(key)
+}
+
+// tagName returns the name given to the field by its tag for key, e.g. item_id
+// for `json:"item_id,omitempty"`, or the field's own name if it has none.
+func tagName(key string, field collector.StructField) string {
+	name := strings.Split(tag(key, field), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+func annotations(doc collector.Comment) []collector.Annotation {
+	return doc.Annotations()
+}
+
+// annotation returns the first annotation of the comment with the name, or
+// nil if it has none.
+func annotation(name string, doc collector.Comment) *collector.Annotation {
+	for _, a := range doc.Annotations() {
+		if a.Name == name {
+			return &a
+		}
+	}
+
+	return nil
+}
+
+func hasAnnotation(name string, doc collector.Comment) bool {
+	return annotation(name, doc) != nil
+}
+
+// indent indents each non-empty line of s by n tabs.
+func indent(n int, s string) string {
+	return prefixNonEmpty(strings.Repeat("\t", n), s)
+}
+
+// prefixLines prefixes each line of s, including empty lines, with prefix,
+// whose trailing spaces are omitted from empty lines.
+func prefixLines(prefix, s string) string {
+	if s == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+			continue
+		}
+		lines[i] = prefix + line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func prefixNonEmpty(prefix, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// wrap wraps the words of each paragraph of s so that its lines are no longer
+// than width, unless a single word is longer.
+func wrap(width int, s string) string {
+	var out []string
+	for _, para := range strings.Split(strings.TrimSuffix(s, "\n"), "\n\n") {
+		var (
+			lines []string
+			line  string
+		)
+		for _, word := range strings.Fields(para) {
+			switch {
+			case line == "":
+				line = word
+			case len(line)+1+len(word) > width:
+				lines = append(lines, line)
+				line = word
+			default:
+				line += " " + word
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(out, "\n\n")
+}
+
+// comment formats s as Go line comments, wrapped at 80 columns.
+func comment(s string) string {
+	return prefixLines("// ", wrap(77, s))
+}
@@ -0,0 +1,624 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// Imports manages the imports of a generated Go file, so that types declared
+// in other packages can be referenced from it. Each package is given a local
+// name which doesn't collide with that of any other imported package, and the
+// import declaration is rendered once the file is complete.
+type Imports struct {
+	pkgPath string
+	names   map[string]string // import path to local name
+	paths   map[string]string // local name to import path
+}
+
+// NewImports returns Imports for a file of the package with the import path
+// pkgPath, whose own types are referenced without a qualifier.
+func NewImports(pkgPath string) *Imports {
+	return &Imports{
+		pkgPath: pkgPath,
+		names:   make(map[string]string),
+		paths:   make(map[string]string),
+	}
+}
+
+// Add imports the package with the import path, returning the local name by
+// which it is referenced, e.g. base for github.com/Fanatics/toast/test/base,
+// or base2 if another package named base is already imported. No import is
+// needed for the file's own package, and an empty name is returned for it.
+func (im *Imports) Add(path string) string {
+	if path == im.pkgPath {
+		return ""
+	}
+	if name, ok := im.names[path]; ok {
+		return name
+	}
+
+	base := packageName(path)
+	name := base
+	for i := 2; im.paths[name] != "" || token.IsKeyword(name) || predeclared[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	im.names[path] = name
+	im.paths[name] = path
+
+	return name
+}
+
+// Type returns the local reference to a type whose identifiers are qualified
+// by the import paths of their packages, as produced by go/types and used by
+// collector.Implementation, importing each of the packages, e.g.
+// []*base.Data for []*github.com/Fanatics/toast/test/base.Data.
+func (im *Imports) Type(typ string) string {
+	return qualifiedIdent.ReplaceAllStringFunc(typ, func(ident string) string {
+		dot := strings.LastIndex(ident, ".")
+		return im.qualify(im.Add(ident[:dot]), ident[dot+1:])
+	})
+}
+
+// SourceType returns the local reference to a type as written in a source
+// file of pkg, e.g. collector.StructField.Type, importing the packages its
+// identifiers refer to. Identifiers qualified by the name of one of the
+// file's imports refer to that package, and the names of types declared in
+// pkg itself refer to pkg, e.g. map[string]test.Item for map[string]Item in
+// a file of github.com/Fanatics/toast/test.
+func (im *Imports) SourceType(pkg collector.Package, file collector.File, typ string) string {
+	imported := make(map[string]string)
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path)
+		if err != nil {
+			path = imp.Path
+		}
+		name := imp.Name
+		if name == "" {
+			name = packageName(path)
+		}
+		imported[name] = path
+	}
+
+	return sourceIdent.ReplaceAllStringFunc(typ, func(ident string) string {
+		if dot := strings.Index(ident, "."); dot > 0 {
+			path, ok := imported[ident[:dot]]
+			if !ok {
+				return ident
+			}
+			return im.qualify(im.Add(path), ident[dot+1:])
+		}
+		if declaresType(pkg, ident) {
+			return im.qualify(im.Add(pkg.Path), ident)
+		}
+		return ident
+	})
+}
+
+// Decl returns the import declaration of the packages imported so far, or an
+// empty string if there are none.
+func (im *Imports) Decl() string {
+	return string(importDecl(im.specs()))
+}
+
+// Render adds the import declaration to Go source code, merging it with any
+// import declarations the source already contains. The source is not
+// formatted, which is left to Gofmt or Goimports.
+func (im *Imports) Render(src []byte) ([]byte, error) {
+	if len(im.names) == 0 {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := im.specs()
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		imp := importSpec{path: path}
+		if spec.Name != nil {
+			imp.name = spec.Name.Name
+		}
+		if !containsImport(imports, imp) {
+			imports = append(imports, imp)
+		}
+	}
+
+	return replaceImports(src, fset, file, imports), nil
+}
+
+// FuncMap returns template functions which use the Imports, to be passed to
+// OutputTemplate:
+//
+//	{{ import "github.com/Fanatics/toast/test/base" }}  // base
+//	{{ qualify "*github.com/Fanatics/toast/test/base.Data" }}  // *base.Data
+//	{{ sourceType $pkg $file $field.Type }}  // base.Data
+func (im *Imports) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"import":     im.Add,
+		"qualify":    im.Type,
+		"sourceType": im.SourceType,
+	}
+}
+
+func (im *Imports) qualify(name, ident string) string {
+	if name == "" {
+		return ident
+	}
+
+	return name + "." + ident
+}
+
+// specs returns the imports, naming those whose local name differs from the
+// last element of their path explicitly.
+func (im *Imports) specs() []importSpec {
+	var specs []importSpec
+	for path, name := range im.names {
+		spec := importSpec{path: path}
+		if name != path[strings.LastIndex(path, "/")+1:] {
+			spec.name = name
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs
+}
+
+var (
+	// qualifiedIdent matches an identifier qualified by an import path, e.g.
+	// github.com/Fanatics/toast/test/base.Data, but not the ... of a
+	// variadic parameter
+	qualifiedIdent = regexp.MustCompile(`[\w~-][\w~.-]*(/[\w~.-]+)*\.[A-Za-z_]\w*`)
+	// sourceIdent matches an identifier, optionally qualified by a package
+	// name, e.g. base.Data
+	sourceIdent = regexp.MustCompile(`\b[A-Za-z_]\w*(\.[A-Za-z_]\w*)?`)
+)
+
+// predeclared holds the identifiers which are implicitly declared in every
+// package, and so can't be used as the name of an import.
+var predeclared = map[string]bool{
+	"any": true, "append": true, "bool": true, "byte": true, "cap": true,
+	"clear": true, "close": true, "comparable": true, "complex": true,
+	"complex64": true, "complex128": true, "copy": true, "delete": true,
+	"error": true, "false": true, "float32": true, "float64": true,
+	"imag": true, "int": true, "int8": true, "int16": true, "int32": true,
+	"int64": true, "iota": true, "len": true, "make": true, "max": true,
+	"min": true, "new": true, "nil": true, "panic": true, "print": true,
+	"println": true, "real": true, "recover": true, "rune": true,
+	"string": true, "true": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+}
+
+// declaresType reports whether the named type is declared in pkg.
+func declaresType(pkg collector.Package, name string) bool {
+	for _, file := range pkg.Files {
+		for _, s := range file.Structs {
+			if s.Name == name {
+				return true
+			}
+		}
+		for _, i := range file.Interfaces {
+			if i.Name == name {
+				return true
+			}
+		}
+		for _, t := range file.TypeDefs {
+			if t.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func containsImport(imports []importSpec, imp importSpec) bool {
+	for _, i := range imports {
+		if i == imp {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Goimports fixes the imports of Go source code, in the style of goimports,
+// and formats it. Imports which are not referenced are removed, and packages
+// of the standard library which are referenced but not imported are added.
+// Only imports whose names are certain are removed, i.e. those of the standard
+// library and those imported with an explicit name, since the name of any
+// other package can't be known from its path, e.g. v1 for k8s.io/api/core/v1.
+// A package of the standard library is only added if every selector on its
+// name refers to one of its exported declarations, since the name may instead
+// be declared by another file of the package.
+func Goimports(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		imports  []importSpec
+		provided = make(map[string]bool)
+		changed  bool
+	)
+	used := referencedPackages(file)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		// cgo depends on the comment preceding its import, so files using it
+		// are left as they are
+		if path == "C" {
+			return Gofmt(src)
+		}
+
+		imp := importSpec{path: path}
+		name, known := packageName(path), isStdlib(path)
+		if spec.Name != nil {
+			imp.name = spec.Name.Name
+			name, known = imp.name, true
+		}
+		if known && name != "_" && name != "." && used[name] == nil {
+			changed = true
+			continue
+		}
+		imports = append(imports, imp)
+		provided[name] = true
+	}
+
+	for name, sels := range used {
+		path, ok := stdlib[name]
+		if !ok || provided[name] || !exportsAll(path, sels) {
+			continue
+		}
+		imports = append(imports, importSpec{path: path})
+		changed = true
+	}
+	if !changed {
+		return Gofmt(src)
+	}
+
+	return Gofmt(replaceImports(src, fset, file, imports))
+}
+
+// replaceImports replaces every import declaration in the source of file with
+// a single declaration of the imports, placed where the first was, or
+// otherwise after the package clause.
+func replaceImports(src []byte, fset *token.FileSet, file *ast.File, imports []importSpec) []byte {
+	start := fset.Position(file.Name.End()).Offset
+	end := start
+	for i, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		if i == 0 {
+			start = fset.Position(gen.Pos()).Offset
+		}
+		end = fset.Position(gen.End()).Offset
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(src[:start])
+	if start == end {
+		buf.WriteString("\n\n")
+	}
+	buf.Write(importDecl(imports))
+	buf.Write(src[end:])
+
+	return buf.Bytes()
+}
+
+type importSpec struct {
+	name, path string
+}
+
+// importDecl returns an import declaration of the imports, grouping those of
+// the standard library before all others.
+func importDecl(imports []importSpec) []byte {
+	if len(imports) == 0 {
+		return nil
+	}
+
+	sort.Slice(imports, func(i, j int) bool {
+		si, sj := isStdlib(imports[i].path), isStdlib(imports[j].path)
+		if si != sj {
+			return si
+		}
+		return imports[i].path < imports[j].path
+	})
+
+	if len(imports) == 1 {
+		if imports[0].name != "" {
+			return []byte(fmt.Sprintf("import %s %q", imports[0].name, imports[0].path))
+		}
+		return []byte(fmt.Sprintf("import %q", imports[0].path))
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("import (\n")
+	for i, imp := range imports {
+		if i > 0 && isStdlib(imports[i-1].path) && !isStdlib(imp.path) {
+			buf.WriteString("\n")
+		}
+		if imp.name != "" {
+			fmt.Fprintf(buf, "\t%s %q\n", imp.name, imp.path)
+		} else {
+			fmt.Fprintf(buf, "\t%q\n", imp.path)
+		}
+	}
+	buf.WriteString(")")
+
+	return buf.Bytes()
+}
+
+// referencedPackages returns the names of the identifiers used as the operand
+// of a selector which aren't declared within the file, i.e. those which may
+// refer to an imported package, along with the names they select.
+func referencedPackages(file *ast.File) map[string][]string {
+	used := make(map[string][]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			used[ident.Name] = append(used[ident.Name], sel.Sel.Name)
+		}
+		return true
+	})
+
+	return used
+}
+
+var (
+	exportsMu sync.Mutex
+	// exports caches the exported declarations of packages of the standard
+	// library, keyed by import path
+	exports = make(map[string]map[string]bool)
+)
+
+// exportsAll reports whether each of the names is an exported declaration of
+// the package of the standard library with the import path. It reports false
+// if the package's source can't be found, e.g. where Go isn't installed.
+func exportsAll(path string, names []string) bool {
+	exportsMu.Lock()
+	decls, ok := exports[path]
+	if !ok {
+		decls = packageExports(path)
+		exports[path] = decls
+	}
+	exportsMu.Unlock()
+
+	for _, name := range names {
+		if !decls[name] {
+			return false
+		}
+	}
+
+	return len(names) > 0
+}
+
+// packageExports returns the exported package-level declarations of the
+// package with the import path, parsed from its source in GOROOT.
+func packageExports(path string) map[string]bool {
+	decls := make(map[string]bool)
+	pkg, err := build.Import(path, "", 0)
+	if err != nil {
+		return decls
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					decls[decl.Name.Name] = decl.Name.IsExported()
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						decls[spec.Name.Name] = spec.Name.IsExported()
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							decls[name.Name] = name.IsExported()
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return decls
+}
+
+// packageName guesses the name of the package with the import path, e.g. yaml
+// for gopkg.in/yaml.v2 and chi for github.com/go-chi/chi/v5.
+func packageName(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && isMajorVersion(name) {
+		name = parts[len(parts)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 && isMajorVersion(name[i+1:]) {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+var (
+	stdlibMu sync.Mutex
+	// stdlibPaths caches whether import paths belong to the standard library
+	stdlibPaths = make(map[string]bool)
+)
+
+// isStdlib reports whether the import path belongs to the standard library,
+// i.e. whether the package is found in GOROOT. Paths which merely lack a domain
+// name, such as those of GOPATH or local modules, e.g. amdm/internal/models,
+// don't. Where GOROOT can't be found, only the packages in stdlib are known.
+func isStdlib(path string) bool {
+	stdlibMu.Lock()
+	defer stdlibMu.Unlock()
+
+	std, ok := stdlibPaths[path]
+	if !ok {
+		std = inGoroot(path)
+		stdlibPaths[path] = std
+	}
+
+	return std
+}
+
+func inGoroot(path string) bool {
+	if strings.Contains(strings.Split(path, "/")[0], ".") {
+		return false
+	}
+
+	root := build.Default.GOROOT
+	if fi, err := os.Stat(filepath.Join(root, "src")); root == "" || err != nil || !fi.IsDir() {
+		for _, p := range stdlib {
+			if p == path {
+				return true
+			}
+		}
+		return false
+	}
+
+	fi, err := os.Stat(filepath.Join(root, "src", filepath.FromSlash(path)))
+	return err == nil && fi.IsDir()
+}
+
+// stdlib maps the names of commonly used packages of the standard library to
+// their import paths. Where several packages share a name, the most commonly
+// used is chosen, e.g. text/template over html/template.
+var stdlib = map[string]string{
+	"adler32":   "hash/adler32",
+	"ast":       "go/ast",
+	"atomic":    "sync/atomic",
+	"base32":    "encoding/base32",
+	"base64":    "encoding/base64",
+	"big":       "math/big",
+	"binary":    "encoding/binary",
+	"bits":      "math/bits",
+	"bufio":     "bufio",
+	"build":     "go/build",
+	"bytes":     "bytes",
+	"context":   "context",
+	"cmplx":     "math/cmplx",
+	"crc32":     "hash/crc32",
+	"csv":       "encoding/csv",
+	"debug":     "runtime/debug",
+	"doc":       "go/doc",
+	"embed":     "embed",
+	"errors":    "errors",
+	"exec":      "os/exec",
+	"expvar":    "expvar",
+	"filepath":  "path/filepath",
+	"flag":      "flag",
+	"fmt":       "fmt",
+	"fnv":       "hash/fnv",
+	"format":    "go/format",
+	"fs":        "io/fs",
+	"gob":       "encoding/gob",
+	"gzip":      "compress/gzip",
+	"hash":      "hash",
+	"heap":      "container/heap",
+	"hex":       "encoding/hex",
+	"hmac":      "crypto/hmac",
+	"html":      "html",
+	"http":      "net/http",
+	"httptest":  "net/http/httptest",
+	"io":        "io",
+	"ioutil":    "io/ioutil",
+	"json":      "encoding/json",
+	"list":      "container/list",
+	"log":       "log",
+	"maps":      "maps",
+	"math":      "math",
+	"md5":       "crypto/md5",
+	"mime":      "mime",
+	"net":       "net",
+	"os":        "os",
+	"parser":    "go/parser",
+	"path":      "path",
+	"pem":       "encoding/pem",
+	"printer":   "go/printer",
+	"rand":      "math/rand",
+	"reflect":   "reflect",
+	"regexp":    "regexp",
+	"ring":      "container/ring",
+	"rsa":       "crypto/rsa",
+	"runtime":   "runtime",
+	"sha1":      "crypto/sha1",
+	"sha256":    "crypto/sha256",
+	"sha512":    "crypto/sha512",
+	"signal":    "os/signal",
+	"slices":    "slices",
+	"slog":      "log/slog",
+	"sort":      "sort",
+	"sql":       "database/sql",
+	"strconv":   "strconv",
+	"strings":   "strings",
+	"sync":      "sync",
+	"syscall":   "syscall",
+	"tabwriter": "text/tabwriter",
+	"template":  "text/template",
+	"testing":   "testing",
+	"tls":       "crypto/tls",
+	"token":     "go/token",
+	"types":     "go/types",
+	"time":      "time",
+	"unicode":   "unicode",
+	"unsafe":    "unsafe",
+	"url":       "net/url",
+	"user":      "os/user",
+	"utf16":     "unicode/utf16",
+	"utf8":      "unicode/utf8",
+	"x509":      "crypto/x509",
+	"xml":       "encoding/xml",
+	"zip":       "archive/zip",
+	"zlib":      "compress/zlib",
+}
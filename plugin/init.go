@@ -14,43 +14,176 @@ import (
 // pointer to collector.Data.
 type Func func(d *collector.Data) error
 
+// Generate calls fn.
+func (fn Func) Generate(d *collector.Data) error {
+	return fn(d)
+}
+
+// Generator defines plugin behavior, like Func. A Generator may be run
+// in-process by a custom build of toast, see the cli package, in which case
+// the data it is passed is shared with other Generators and must not be
+// modified.
+type Generator interface {
+	Generate(d *collector.Data) error
+}
+
 type Plugin struct {
-	name string
+	name      string
+	handshake collector.Handshake
+	params    Params
+	files     []*File
+
+	outputBase  string
+	diagnostics collector.Diagnostics
 }
 
 // New returns a Plugin instance for a Plugin to be initialized.
 func New(name string) *Plugin {
 	return &Plugin{
 		name: name,
+		handshake: collector.Handshake{
+			Name:      name,
+			Encodings: encodings(),
+		},
 	}
 }
 
+// Requires declares the oldest protocol version of toast the Plugin supports,
+// and the features of the data it requires, e.g. collector.FeatureTypes. toast
+// fails before running any plugins if it can't meet the requirements.
+func (p *Plugin) Requires(protocolVersion int, features ...string) *Plugin {
+	p.handshake.MinProtocolVersion = protocolVersion
+	p.handshake.Features = append(p.handshake.Features, features...)
+	return p
+}
+
+// Filter declares the parts of the data the Plugin uses, so that toast only
+// sends it those.
+func (p *Plugin) Filter(f collector.Filter) *Plugin {
+	p.handshake.Filter = &f
+	return p
+}
+
 // Init is called by Plugin code and is provided a PluginFunc from the caller
-// to handle the input Data (read from stdin).
+// to handle the input Data (read from stdin). If the Func returns an error, or
+// reports an error with Report, no files are written and the Plugin exits
+// with a non-zero status once its diagnostics have been sent to toast.
 func (p *Plugin) Init(fn Func) {
+	// toast asks for the plugin's requirements before sending it any data
+	if os.Getenv(collector.HandshakeEnv) != "" {
+		if err := json.NewEncoder(os.Stdout).Encode(p.handshake); err != nil {
+			p.Report(diagnostic(err))
+			p.printDiagnostics()
+			p.exit()
+		}
+		return
+	}
+
+	var files []collector.GeneratedFile
+	data, err := p.decode()
+	if err != nil {
+		p.Report(diagnostic(err))
+	} else {
+		files = p.generate(fn, data)
+	}
+	p.respond(files)
+	p.exit()
+}
+
+// Run runs the Generator in-process against the data, as Init does with the
+// data it reads from stdin, and returns the files it generated along with the
+// diagnostics it reported, rather than writing them. It is used by toast to
+// run Generators registered with it, see the cli package.
+func (p *Plugin) Run(g Generator, d *collector.Data) (resp collector.Response) {
+	p.files, p.diagnostics = nil, nil
+	defer func() {
+		// a panic must not bring down toast and every other plugin with it
+		if r := recover(); r != nil {
+			p.Report(diagnostic(fmt.Errorf("panic: %v", r)))
+			resp = collector.Response{Diagnostics: p.diagnostics}
+		}
+	}()
+
+	files := p.generate(g, d)
+	return collector.Response{
+		Files:       files,
+		Diagnostics: p.diagnostics,
+	}
+}
+
+// decode reads the input data from stdin.
+func (p *Plugin) decode() (*collector.Data, error) {
 	// read from stdin to get serialized bytes
 	input := &bytes.Buffer{}
 	_, err := io.Copy(input, os.Stdin)
 	if err != nil {
-		p.wrapErrAndLog(err)
-		return
+		return nil, err
 	}
 
-	// deserialize bytes into *collector.Data
-	inputData := &collector.Data{}
-	err = json.Unmarshal(input.Bytes(), inputData)
+	// deserialize bytes into *collector.Data, detecting the encoding used
+	inputData, err := collector.Decode(input.Bytes())
 	if err != nil {
-		p.wrapErrAndLog(err)
-		return
+		return nil, err
+	}
+
+	// guard against versions of toast which predate the handshake
+	if inputData.ProtocolVersion < p.handshake.MinProtocolVersion {
+		return nil, fmt.Errorf(
+			"plugin requires protocol version %d, but toast %s provided version %d",
+			p.handshake.MinProtocolVersion, inputData.ToastVersion, inputData.ProtocolVersion,
+		)
 	}
 
-	// execute "fn" and pass it the *collector.Data, where the Plugin would use
-	// the simplified AST to generate other code.
-	p.wrapErrAndLog(fn(inputData))
+	return inputData, nil
 }
 
-func (p *Plugin) wrapErrAndLog(err error) {
+// generate executes the Generator, returning the files it created, or none if
+// it failed.
+func (p *Plugin) generate(g Generator, d *collector.Data) []collector.GeneratedFile {
+	p.params = Params(d.Parameters)
+	p.outputBase = d.OutputBase
+
+	// execute the Generator and pass it the *collector.Data, where the Plugin
+	// would use the simplified AST to generate other code.
+	err := g.Generate(d)
+	var files []collector.GeneratedFile
+	if err == nil {
+		// files created with NewFile are only written once all of them have
+		// been generated successfully
+		files, err = p.generateFiles()
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stdout, "[toast:plugin] %s: %v\n", p.name, err)
+		p.Report(diagnostic(err))
+	}
+	if p.diagnostics.HasErrors() {
+		return nil
 	}
+
+	return files
+}
+
+// Name returns the name of the Plugin.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Handshake returns the requirements and capabilities declared by the Plugin.
+func (p *Plugin) Handshake() collector.Handshake {
+	return p.handshake
+}
+
+// Params returns the parameters passed to the Plugin by the user. They are
+// available once Init has decoded the input data, i.e. from within its Func.
+func (p *Plugin) Params() Params {
+	return p.params
+}
+
+// encodings lists every encoding which collector.Decode detects.
+func encodings() []string {
+	var all []string
+	for _, enc := range []string{collector.JSONEncoding, collector.ProtoEncoding} {
+		all = append(all, enc, enc+collector.GzipSuffix)
+	}
+
+	return all
 }
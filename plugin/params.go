@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Params holds the parameters passed to a plugin by the user, e.g. with
+// --plugin "gen:out=./db:params=table=items,verbose". The getters return the
+// provided default if a parameter is not set, and an error if it is set to a
+// value which can't be converted to the requested type.
+type Params map[string]string
+
+// String returns the value of the parameter.
+func (p Params) String(key, def string) string {
+	if v, ok := p[key]; ok {
+		return v
+	}
+
+	return def
+}
+
+// Required returns the value of the parameter, or an error if it is not set.
+func (p Params) Required(key string) (string, error) {
+	v, ok := p[key]
+	if !ok {
+		return "", fmt.Errorf("missing required parameter: %s", key)
+	}
+
+	return v, nil
+}
+
+// Bool returns the value of the parameter as a bool, e.g. true, false, 1 or 0.
+func (p Params) Bool(key string, def bool) (bool, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, invalidParam(key, v, "a bool")
+	}
+
+	return b, nil
+}
+
+// Int returns the value of the parameter as an int.
+func (p Params) Int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def, invalidParam(key, v, "an int")
+	}
+
+	return i, nil
+}
+
+// Duration returns the value of the parameter as a time.Duration, e.g. 1m30s.
+func (p Params) Duration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, invalidParam(key, v, "a duration")
+	}
+
+	return d, nil
+}
+
+// List returns the value of the parameter split by "|", since "," separates
+// parameters, e.g. formats=json|csv.
+func (p Params) List(key string, def []string) []string {
+	v, ok := p[key]
+	if !ok {
+		return def
+	}
+	if v == "" {
+		return nil
+	}
+
+	return strings.Split(v, "|")
+}
+
+// OneOf returns the value of the parameter, or an error if it is not one of
+// the allowed values.
+func (p Params) OneOf(key, def string, allowed ...string) (string, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	for _, a := range allowed {
+		if v == a {
+			return v, nil
+		}
+	}
+
+	return def, invalidParam(key, v, "one of "+strings.Join(allowed, ", "))
+}
+
+// Check returns an error if any parameters other than the known ones are set,
+// which usually indicates a typo by the user.
+func (p Params) Check(known ...string) error {
+	var unknown []string
+	for key := range p {
+		if !contains(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if unknown == nil {
+		return nil
+	}
+
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown parameters: %s", strings.Join(unknown, ", "))
+}
+
+func invalidParam(key, value, expected string) error {
+	return fmt.Errorf("invalid parameter %s=%q: expected %s", key, value, expected)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
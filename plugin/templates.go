@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"text/template"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// TemplatesParam is the parameter with which users pass a directory of
+// templates to a plugin, overriding those it ships with, e.g.
+// --plugin "gen:out=./db:params=templates=./templates".
+const TemplatesParam = "templates"
+
+// Templates is a set of text templates loaded from an fs.FS, such as an
+// embed.FS, so that a plugin can ship its templates within its binary. The
+// templates may call each other, and templates defined with define or block
+// may be overridden by templates loaded later, e.g. from the user's repo.
+//
+// The functions of FuncMap are available to every template. Those of
+// Imports.FuncMap (import, qualify and sourceType) may be parsed in any
+// template, but fail when executed unless an Imports.FuncMap is passed to
+// Execute, so that a file's imports can't be silently lost.
+type Templates struct {
+	tmpl *template.Template
+}
+
+// LoadTemplates parses the files within fsys matching any of the patterns, as
+// with fs.Glob, e.g. "templates/*.tmpl" and "templates/partials/*.tmpl". Each
+// template is named by the base name of its file. An error is returned if no
+// files match.
+func LoadTemplates(fsys fs.FS, patterns ...string) (*Templates, error) {
+	funcs := FuncMap()
+	for name, fn := range importsPlaceholders() {
+		funcs[name] = fn
+	}
+
+	t := &Templates{
+		tmpl: template.New("").Funcs(funcs),
+	}
+	if err := t.Override(fsys, patterns...); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Templates loads the Plugin's templates from fsys as LoadTemplates does, then
+// overrides them with any templates within the directory passed by the user
+// as the TemplatesParam parameter, matching *.tmpl.
+func (p *Plugin) Templates(fsys fs.FS, patterns ...string) (*Templates, error) {
+	t, err := LoadTemplates(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := p.params.String(TemplatesParam, ""); dir != "" {
+		if err := t.Override(os.DirFS(dir), "*.tmpl"); err != nil {
+			return nil, fmt.Errorf("%s parameter: %v", TemplatesParam, err)
+		}
+	}
+
+	return t, nil
+}
+
+// Override parses the files within fsys matching any of the patterns into the
+// set. Templates they define replace those of the same name, so that a single
+// partial or block can be overridden without copying the templates using it.
+func (t *Templates) Override(fsys fs.FS, patterns ...string) error {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no templates match %v", patterns)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		if _, err := t.tmpl.New(path.Base(file)).Parse(string(b)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importsPlaceholders returns funcs standing in for those of Imports.FuncMap
+// while templates are parsed, which fail if they're called instead.
+func importsPlaceholders() template.FuncMap {
+	errNoImports := func(name string) error {
+		return fmt.Errorf("%s used without Imports.FuncMap", name)
+	}
+
+	return template.FuncMap{
+		"import": func(string) (string, error) {
+			return "", errNoImports("import")
+		},
+		"qualify": func(string) (string, error) {
+			return "", errNoImports("qualify")
+		},
+		"sourceType": func(collector.Package, collector.File, string) (string, error) {
+			return "", errNoImports("sourceType")
+		},
+	}
+}
+
+// Execute executes the named template using the provided data and writes it to
+// the destination io.Writer. Any funcs, such as those of Imports.FuncMap, take
+// precedence over the functions available when the templates were parsed.
+func (t *Templates) Execute(dst io.Writer, name string, data interface{}, funcs ...template.FuncMap) error {
+	tmpl := t.tmpl
+	if len(funcs) > 0 {
+		var err error
+		if tmpl, err = t.tmpl.Clone(); err != nil {
+			return err
+		}
+		for _, fm := range funcs {
+			tmpl.Funcs(fm)
+		}
+	}
+
+	return tmpl.ExecuteTemplate(dst, name, data)
+}
+
+// Names returns the names of the templates in the set, including those defined
+// within files, in sorted order.
+func (t *Templates) Names() []string {
+	var names []string
+	for _, tmpl := range t.tmpl.Templates() {
+		if tmpl.Name() != "" {
+			names = append(names, tmpl.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
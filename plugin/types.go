@@ -0,0 +1,211 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Fanatics/toast/collector"
+)
+
+// GoType renders a type collected by toast in Go syntax, as it was written in
+// source, e.g. []*base.Data. It accepts a collector.StructField, whose flags
+// are taken into account, a collector.Value, or the Type of a StructField, in
+// any of its forms: a string, a collector.ValueType, or the generic map a
+// ValueType is decoded to from JSON.
+func GoType(v interface{}) string {
+	switch v := v.(type) {
+	case collector.StructField:
+		return fieldType(v)
+	case *collector.StructField:
+		return fieldType(*v)
+	case collector.Value:
+		return v.Type
+	case *collector.Value:
+		return v.Type
+	}
+
+	return typeValue(v, "")
+}
+
+func fieldType(f collector.StructField) string {
+	typ := typeValue(f.Type, f.ArrayLen)
+	if f.Indirect {
+		typ = "*" + typ
+	}
+
+	switch {
+	case f.IsSlice:
+		return "[]" + typ
+	case f.IsArray:
+		return "[" + f.ArrayLen + "]" + typ
+	}
+
+	return typ
+}
+
+// typeValue renders the type held by the Type of a StructField, where
+// arrayLen is the length of a pointer to an array.
+func typeValue(v interface{}, arrayLen string) string {
+	var vt collector.ValueType
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case collector.ValueType:
+		vt = v
+	default:
+		if err := convert(v, &vt); err != nil || vt.Kind == "" {
+			return fmt.Sprint(v)
+		}
+	}
+
+	switch vt.Kind {
+	case "slice":
+		return "[]" + typeValue(vt.Value, "")
+	case "array":
+		return "[" + arrayLen + "]" + typeValue(vt.Value, "")
+	case "map":
+		var m collector.Map
+		convert(vt.Value, &m)
+		return mapType(m)
+	case "chan":
+		var ch collector.Channel
+		convert(vt.Value, &ch)
+		return chanType(ch)
+	case "func":
+		var fn collector.Func
+		convert(vt.Value, &fn)
+		return "func" + signature(fn.Params, fn.Results)
+	case "struct":
+		var s collector.Struct
+		convert(vt.Value, &s)
+		return structType(s)
+	case "interface":
+		var i collector.Interface
+		convert(vt.Value, &i)
+		return interfaceType(i)
+	}
+
+	return typeValue(vt.Value, arrayLen)
+}
+
+func mapType(m collector.Map) string {
+	val := m.ValueType
+	var elem string
+	switch val.Name {
+	case "map":
+		var vm collector.Map
+		convert(val.Value, &vm)
+		elem = mapType(vm)
+	case "chan":
+		var ch collector.Channel
+		convert(val.Value, &ch)
+		elem = chanType(ch)
+	case "func":
+		var fn collector.Func
+		convert(val.Value, &fn)
+		elem = "func" + signature(fn.Params, fn.Results)
+	default:
+		elem = fmt.Sprint(val.Value)
+	}
+
+	return "map[" + m.KeyType + "]" + elem
+}
+
+func chanType(ch collector.Channel) string {
+	elem := typeValue(ch.Type, "")
+	switch {
+	case ch.RecvOnly:
+		return "<-chan " + elem
+	case ch.SendOnly:
+		return "chan<- " + elem
+	}
+
+	return "chan " + elem
+}
+
+// signature renders the params and results of a func, e.g. (ctx
+// context.Context) error.
+func signature(params, results []collector.Value) string {
+	sig := "(" + values(params) + ")"
+	switch {
+	case len(results) == 1 && results[0].Name == nil:
+		sig += " " + results[0].Type
+	case len(results) > 0:
+		sig += " (" + values(results) + ")"
+	}
+
+	return sig
+}
+
+func values(vals []collector.Value) string {
+	parts := make([]string, len(vals))
+	for i, v := range vals {
+		parts[i] = v.Type
+		if v.Name != nil {
+			parts[i] = *v.Name + " " + v.Type
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func structType(s collector.Struct) string {
+	if len(s.Fields) == 0 {
+		return "struct{}"
+	}
+
+	parts := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		parts[i] = fieldType(f)
+		if f.Name != "" {
+			parts[i] = f.Name + " " + parts[i]
+		}
+		if f.Tag != "" {
+			parts[i] += " " + f.Tag
+		}
+	}
+
+	return "struct{ " + strings.Join(parts, "; ") + " }"
+}
+
+func interfaceType(i collector.Interface) string {
+	if len(i.MethodSet) == 0 {
+		return "interface{}"
+	}
+
+	var parts []string
+	for _, field := range i.MethodSet {
+		if fn, ok := field.Func(); ok {
+			parts = append(parts, fn.Name+signature(fn.Params, fn.Results))
+		}
+		if embed, ok := field.Interface(); ok {
+			parts = append(parts, embed.Name)
+		}
+		if terms, ok := field.TypeTerms(); ok {
+			union := make([]string, len(terms))
+			for j, t := range terms {
+				union[j] = t.Type
+				if t.Tilde {
+					union[j] = "~" + t.Type
+				}
+			}
+			parts = append(parts, strings.Join(union, " | "))
+		}
+	}
+
+	return "interface{ " + strings.Join(parts, "; ") + " }"
+}
+
+// convert converts v, which is either of the type of dst or its generic form
+// decoded from JSON, to the type of dst.
+func convert(v interface{}, dst interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, dst)
+}
@@ -31,17 +31,36 @@ func GofmtReadWriter(rw io.ReadWriter) (io.ReadWriter, error) {
 }
 
 // OutputTemplate executes a text template using the provided data and writes it
-// to the destination io.Writer.
-func (p *Plugin) OutputTemplate(dst io.Writer, templatePath string, data interface{}) error {
-	return template.Must(
-		template.New(path.Base(templatePath)).ParseFiles(templatePath),
-	).Execute(dst, data)
+// to the destination io.Writer. The template is read from templatePath, which
+// is relative to the directory toast is run from; see Templates for templates
+// shipped with the plugin itself. The functions of FuncMap are available to the
+// template, along with any funcs, such as those of Imports.FuncMap, which
+// take precedence over them.
+func (p *Plugin) OutputTemplate(dst io.Writer, templatePath string, data interface{}, funcs ...template.FuncMap) error {
+	tmpl := template.New(path.Base(templatePath)).Funcs(FuncMap())
+	for _, fm := range funcs {
+		tmpl.Funcs(fm)
+	}
+
+	if _, err := tmpl.ParseFiles(templatePath); err != nil {
+		return err
+	}
+
+	return tmpl.Execute(dst, data)
 }
 
 // OutputTemplateHTML executes an HTML template using the provided data and
-// writes it to the destination io.Writer.
-func (p *Plugin) OutputTemplateHTML(dst io.Writer, templatePath string, data interface{}) error {
-	return htmltmpl.Must(
-		htmltmpl.New(path.Base(templatePath)).ParseFiles(templatePath),
-	).Execute(dst, data)
+// writes it to the destination io.Writer. The functions of FuncMap are
+// available to the template, along with any funcs.
+func (p *Plugin) OutputTemplateHTML(dst io.Writer, templatePath string, data interface{}, funcs ...template.FuncMap) error {
+	tmpl := htmltmpl.New(path.Base(templatePath)).Funcs(FuncMap())
+	for _, fm := range funcs {
+		tmpl.Funcs(fm)
+	}
+
+	if _, err := tmpl.ParseFiles(templatePath); err != nil {
+		return err
+	}
+
+	return tmpl.Execute(dst, data)
 }
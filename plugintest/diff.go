@@ -0,0 +1,117 @@
+package plugintest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Diff returns a line-based diff of want and got, in the style of a unified
+// diff: removed lines are prefixed with "-", added lines with "+", and the
+// unchanged lines around them with a space. Runs of unchanged lines further
+// from a change are elided with a hunk header giving the line numbers which
+// follow. It returns "" if want and got are equal.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	a, b := lines(want), lines(got)
+	ops := edits(a, b)
+
+	// show each line within diffContext lines of a change
+	show := make([]bool, len(ops))
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			if j >= 0 && j < len(ops) {
+				show[j] = true
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("--- want\n+++ got\n")
+	for i, op := range ops {
+		if !show[i] {
+			continue
+		}
+		if i == 0 || !show[i-1] {
+			fmt.Fprintf(&out, "@@ -%d +%d @@\n", op.a+1, op.b+1)
+		}
+		line := a
+		idx := op.a
+		if op.kind == '+' {
+			line, idx = b, op.b
+		}
+		fmt.Fprintf(&out, "%c%s\n", op.kind, line[idx])
+	}
+
+	return out.String()
+}
+
+// edit is a line of a diff, kept (' ') or removed ('-') from a, or added ('+')
+// from b, with the indexes of the lines of a and b it's at.
+type edit struct {
+	kind rune
+	a, b int
+}
+
+// edits returns the shortest script editing a into b, using the longest common
+// subsequence of their lines.
+func edits(a, b []string) []edit {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, edit{' ', i, j})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, edit{'-', i, j})
+			i++
+		default:
+			ops = append(ops, edit{'+', i, j})
+			j++
+		}
+	}
+
+	return ops
+}
+
+// lines splits s into its lines, marking a missing final newline, so that it
+// shows in a diff.
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n\\ no newline at end of file\n"
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,324 @@
+// Package plugintest tests toast plugins against fixture packages, comparing
+// the files they generate with golden files, e.g.
+//
+//	func TestGenerate(t *testing.T) {
+//		p := plugintest.InProcess(plugin.New("amdm_gen_db"), plugin.Func(generate))
+//		resp := p.Run(t, "testdata/items", map[string]string{"table": "items"})
+//		plugintest.Golden(t, "testdata/items.golden", resp.Files)
+//	}
+//
+// The golden files are written, rather than compared, when the tests are run
+// with the -update flag, e.g. go test ./internal/gen -update.
+package plugintest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Fanatics/toast/collector"
+	"github.com/Fanatics/toast/plugin"
+)
+
+var update = flag.Bool("update", false, "write the files generated by plugins as golden files, rather than comparing them")
+
+// Plugin is a plugin under test, either a Generator run in-process, or an
+// executable run as toast runs it.
+type Plugin struct {
+	plugin *plugin.Plugin
+	gen    plugin.Generator
+	// name and args are those of the executable, if the plugin isn't run
+	// in-process
+	name string
+	args []string
+	// shakes is set if the executable supports the handshake
+	shakes bool
+}
+
+// InProcess returns a Plugin running g, with p providing its files,
+// parameters and diagnostics, in the same way as a Generator registered with
+// toast's cli package.
+func InProcess(p *plugin.Plugin, g plugin.Generator) *Plugin {
+	return &Plugin{
+		plugin: p,
+		gen:    g,
+	}
+}
+
+// Command returns a Plugin running the executable with the name, or path,
+// and args.
+func Command(name string, args ...string) *Plugin {
+	return &Plugin{
+		name: name,
+		args: args,
+	}
+}
+
+// WithHandshake declares that the executable supports the handshake, as the
+// handshake option of the -plugin flag does, so that it's asked for its
+// requirements before it's run. It has no effect on plugins run in-process,
+// whose handshake is always known.
+func (p *Plugin) WithHandshake() *Plugin {
+	p.shakes = true
+	return p
+}
+
+// Build builds the main package pkg, e.g. "." for the package of the test,
+// and returns the path of its executable, which is removed once the test
+// completes.
+func Build(t testing.TB, pkg string) string {
+	t.Helper()
+
+	exe := filepath.Join(t.TempDir(), "plugin")
+	out, err := exec.Command("go", "build", "-o", exe, pkg).CombinedOutput()
+	if err != nil {
+		t.Fatalf("building %s: %v\n%s", pkg, err, out)
+	}
+
+	return exe
+}
+
+// Run collects the packages in and below the fixture directory dir, runs the
+// plugin with params, and returns its response. The data is collected with
+// the features required by the plugin's handshake, and filtered by its filter,
+// as toast would. The test fails if the fixture can't be collected, or if the
+// plugin fails without reporting an error diagnostic, which are left to the
+// test to check.
+func (p *Plugin) Run(t testing.TB, dir string, params map[string]string) collector.Response {
+	t.Helper()
+
+	hs := p.handshake(t)
+	if err := hs.Check(); err != nil {
+		t.Fatalf("plugin %s: %v", p.String(), err)
+	}
+
+	data, diags := collector.Load(nil, collector.Options{
+		Dir:    dir,
+		Tests:  true,
+		Types:  hs.Requires(collector.FeatureTypes),
+		Bodies: hs.Requires(collector.FeatureBodies),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("loading %s:\n%s", dir, diagnostics(diags))
+	}
+	if hs.Filter != nil {
+		data = data.Filter(*hs.Filter)
+	}
+
+	outputBase := t.TempDir()
+	var (
+		resp collector.Response
+		err  error
+	)
+	if p.gen != nil {
+		d := *data
+		d.OutputBase = outputBase
+		d.Parameters = params
+		resp = p.plugin.Run(p.gen, &d)
+	} else {
+		resp, err = p.exec(t, data, outputBase, params)
+		if err != nil && !resp.Diagnostics.HasErrors() {
+			t.Fatalf("plugin %s: %v\n%s", p.String(), err, diagnostics(resp.Diagnostics))
+		}
+	}
+	resp.Diagnostics.Sort()
+
+	return resp
+}
+
+// String returns the name of the plugin.
+func (p *Plugin) String() string {
+	if p.plugin != nil {
+		return p.plugin.Name()
+	}
+
+	return p.name
+}
+
+// handshake returns the plugin's handshake, which is empty for executables
+// which don't support it, or fail to respond with one, as it is when toast
+// runs them.
+func (p *Plugin) handshake(t testing.TB) collector.Handshake {
+	if p.plugin != nil {
+		return p.plugin.Handshake()
+	}
+	var hs collector.Handshake
+	if !p.shakes {
+		return hs
+	}
+
+	cmd := exec.Command(p.name, p.args...)
+	cmd.Env = append(os.Environ(), collector.HandshakeEnv+"=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Logf("plugin %s: no handshake: %v", p.String(), err)
+		return hs
+	}
+	if err := json.Unmarshal(out, &hs); err != nil {
+		t.Logf("plugin %s: no handshake: invalid handshake: %v", p.String(), err)
+		return collector.Handshake{}
+	}
+
+	return hs
+}
+
+// exec runs the executable with the data, returning the files it sends back
+// in its response, as well as those it writes under outputBase itself.
+func (p *Plugin) exec(t testing.TB, data *collector.Data, outputBase string, params map[string]string) (collector.Response, error) {
+	var resp collector.Response
+	b, err := collector.NewEncoder(data).Encode(collector.JSONEncoding, outputBase, params)
+	if err != nil {
+		return resp, err
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return resp, err
+	}
+	defer pr.Close()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.name, p.args...)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.ExtraFiles = []*os.File{pw}
+	cmd.Env = append(os.Environ(), collector.ResponseFDEnv+"=3")
+
+	response := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(pr)
+		response <- b
+	}()
+	runErr := cmd.Start()
+	pw.Close()
+	if runErr == nil {
+		runErr = cmd.Wait()
+	}
+	out := <-response
+
+	if stdout.Len() > 0 {
+		t.Logf("plugin %s stdout:\n%s", p.String(), stdout.Bytes())
+	}
+	if stderr.Len() > 0 {
+		t.Logf("plugin %s stderr:\n%s", p.String(), stderr.Bytes())
+	}
+	if len(out) > 0 {
+		if err := json.Unmarshal(out, &resp); err != nil {
+			return resp, fmt.Errorf("invalid response: %v", err)
+		}
+	}
+
+	written, err := readFiles(outputBase)
+	if err != nil {
+		return resp, err
+	}
+	resp.Files = append(resp.Files, written...)
+
+	return resp, runErr
+}
+
+// Golden compares the files with the golden files in dir, which are named by
+// the names of the files relative to it, and fails the test with a diff of
+// each file which differs, as well as for any files generated without a
+// golden file or vice versa. With the -update flag, dir is replaced by the
+// files instead.
+func Golden(t testing.TB, dir string, files []collector.GeneratedFile) {
+	t.Helper()
+
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if err := f.Write(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	golden, err := readFiles(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	want := make(map[string]string)
+	for _, f := range golden {
+		want[f.Name] = f.Content
+	}
+
+	got := make(map[string]bool)
+	for _, f := range sorted(files) {
+		name := filepath.ToSlash(filepath.Clean(f.Name))
+		got[name] = true
+
+		content, ok := want[name]
+		if !ok {
+			t.Errorf("%s was generated, but has no golden file in %s (run with -update to add it)", name, dir)
+			continue
+		}
+		if content != f.Content {
+			t.Errorf("%s differs from its golden file (run with -update to accept it):\n%s", name, Diff(content, f.Content))
+		}
+	}
+	for _, f := range golden {
+		if !got[f.Name] {
+			t.Errorf("%s has a golden file in %s, but wasn't generated (run with -update to remove it)", f.Name, dir)
+		}
+	}
+}
+
+// readFiles returns the files in and below dir, named by their slash-separated
+// paths relative to it.
+func readFiles(dir string) ([]collector.GeneratedFile, error) {
+	var files []collector.GeneratedFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, collector.GeneratedFile{
+			Name:    filepath.ToSlash(rel),
+			Content: string(b),
+		})
+		return nil
+	})
+
+	return sorted(files), err
+}
+
+func sorted(files []collector.GeneratedFile) []collector.GeneratedFile {
+	files = append([]collector.GeneratedFile(nil), files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return files
+}
+
+// diagnostics formats diags one per line, as toast prints them.
+func diagnostics(diags collector.Diagnostics) string {
+	var b bytes.Buffer
+	for _, d := range diags {
+		fmt.Fprintln(&b, d)
+	}
+
+	return b.String()
+}